@@ -1,15 +1,24 @@
 package main
 
-import "ebmgo/cmd"
+import (
+	"ebmgo/cmd"
+	"ebmgo/config"
+)
 
 var Apps map[string]run = map[string]run{
-	"import": {description: "import books from given path", run: cmd.Import},
-	"list":   {description: "list books in ebm directory", run: cmd.ListBooks},
-	"remove": {description: "Remove books in ebm directory by ids", run: cmd.RemoveBooks},
-	"export": {description: "export books to given path", run: cmd.Export},
+	"import":     {description: "import books from given path", run: cmd.Import},
+	"list":       {description: "list books in ebm directory", run: cmd.ListBooks},
+	"check":      {description: "check that the db and the ebm directory agree", run: cmd.CheckLibrary},
+	"rebuild-db": {description: "rebuild the db from books in ebm directory", run: cmd.RebuildDB},
+	"edit":       {description: "edit metadata of books in ebm directory", run: cmd.EditBooks},
+	"remove":     {description: "Remove books in ebm directory by ids", run: cmd.RemoveBooks},
+	"relayout":   {description: "move book files to match the path template", run: cmd.Relayout},
+	"library":    {description: "list, add, remove named libraries and set the default one", run: cmd.Library},
+	"move":       {description: "move books to another library", run: cmd.MoveBooks},
+	"export":     {description: "export books to given path", run: cmd.Export},
 }
 
 type run struct {
 	description string
-	run         func(call []string) error
+	run         func(cfg config.Config, call []string) error
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
@@ -16,9 +17,16 @@ var (
 )
 
 type collector struct {
-	books    []bookmanager.Book
-	titleMap map[string]int
-	mu       sync.Mutex
+	books           []bookmanager.Book
+	titleMap        map[string]int // book index by title
+	unsupported     []string
+	failed          []FileError
+	continueOnError bool
+	skip            func(path string) bool
+	progress        func(Progress)
+	discovered      int
+	parsed          int
+	mu              sync.Mutex
 }
 
 func newCollector() *collector {
@@ -28,28 +36,76 @@ func newCollector() *collector {
 	}
 }
 
+// Progress is a progress event of Find, sent after every file found and
+// every file parsed.
+type Progress struct {
+	Discovered int    // files found so far
+	Parsed     int    // files parsed so far, including unsupported and failed files
+	Path       string // file just found or parsed
+}
+
+// count counts a file found or parsed and sends a progress event.
+func (c *collector) count(discovered, parsed int, path string) {
+	if c.progress == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.discovered += discovered
+	c.parsed += parsed
+	c.progress(Progress{Discovered: c.discovered, Parsed: c.parsed, Path: path})
+}
+
+// FileError is a file that could not be parsed.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+// newBook returns a book from parsed ebook metadata and file.
+func newBook(f bookparser.BookParser) bookmanager.Book {
+	book := bookmanager.NewBook(
+		f.Metadata.ISBN,
+		f.Metadata.Title,
+		f.Metadata.Authors,
+		f.Metadata.Publisher,
+		f.Metadata.Tags,
+	)
+	book.ASIN = f.Metadata.ASIN
+	book.Language = f.Metadata.Language
+	book.Description = f.Metadata.Description
+	book.PublishDate = f.Metadata.PublishDate
+	book.Series = f.Metadata.Series
+	book.SeriesIndex = f.Metadata.SeriesIndex
+	book.Cover = f.Metadata.Cover
+	book.AppendFiles(f.File.Path, f.File.Type)
+
+	return book
+}
+
 func (c *collector) addOrAppendBook(path string) error {
 	f, err := bookparser.Parse(path)
 	if err != nil {
 		return err
 	}
 
+	// Files titled by their file name are only books of the same title in
+	// the same directory, e.g. README.txt files of different books are not.
+	key := f.Metadata.Title
+	if f.Metadata.FileTitle {
+		key = filepath.Dir(path) + "\x00" + key
+	}
+
 	c.mu.Lock()
-	i, found := c.titleMap[f.Metadata.Title]
+	i, found := c.titleMap[key]
 	if found {
 		c.books[i].AppendFiles(f.File.Path, f.File.Type)
 	} else {
-		book := bookmanager.NewBook(
-			f.Metadata.ISBN,
-			f.Metadata.Title,
-			f.Metadata.Authors,
-			f.Metadata.Publisher,
-			f.Metadata.Tags,
-		)
-		book.AppendFiles(f.File.Path, f.File.Type)
+		book := newBook(f)
 		c.books = append(c.books, book)
 
-		c.titleMap[f.Metadata.Title] = len(c.books) - 1
+		c.titleMap[key] = len(c.books) - 1
 	}
 	c.mu.Unlock()
 
@@ -65,10 +121,16 @@ func (c *collector) walkDir(ctx context.Context, cancel context.CancelCauseFunc,
 
 	for _, item := range files {
 		filePath := filepath.Join(path, item.Name())
-		if item.IsDir() && recursive {
-			c.walkDir(ctx, cancel, recursive, filePath, jobs)
+		if item.IsDir() {
+			if recursive {
+				c.walkDir(ctx, cancel, recursive, filePath, jobs)
+			}
 			continue
 		}
+		if c.skip != nil && c.skip(filePath) {
+			continue
+		}
+		c.count(1, 0, filePath)
 		jobs <- filePath
 	}
 }
@@ -91,10 +153,17 @@ func (c *collector) getEbooks(worker int, recursive bool, path string) error {
 						return
 					}
 					err := c.addOrAppendBook(filePath)
-					if err != nil {
-						if err != bookparser.ErrNotSupportMimeType {
-							cancel(fmt.Errorf("error add or append book %s %v", filePath, err))
-						}
+					c.count(0, 1, filePath)
+					if err == bookparser.ErrNotSupportMimeType {
+						c.mu.Lock()
+						c.unsupported = append(c.unsupported, filePath)
+						c.mu.Unlock()
+					} else if err != nil && c.continueOnError {
+						c.mu.Lock()
+						c.failed = append(c.failed, FileError{Path: filePath, Err: err})
+						c.mu.Unlock()
+					} else if err != nil {
+						cancel(fmt.Errorf("error add or append book %s %v", filePath, err))
 					}
 				}
 			}
@@ -115,39 +184,80 @@ func (c *collector) getEbooks(worker int, recursive bool, path string) error {
 	return nil
 }
 
+// Result is the books found in a path.
+type Result struct {
+	Books []bookmanager.Book
+	// Unsupported are the files skipped because their type is not supported.
+	Unsupported []string
+	// Failed are the files that could not be parsed, with
+	// FindOptions.ContinueOnError.
+	Failed []FileError
+}
+
+// FindOptions controls how Find searches books.
+type FindOptions struct {
+	// Worker is the number of files parsed concurrently.
+	Worker int
+	// Recursive searches subdirectories too.
+	Recursive bool
+	// ContinueOnError records files that can not be parsed in Result.Failed
+	// instead of stopping at the first one.
+	ContinueOnError bool
+	// Skip reports whether a file is left out without parsing it, e.g. a
+	// file already imported by an interrupted import.
+	Skip func(path string) bool
+	// Progress receives progress events of a directory search, calls are
+	// not concurrent.
+	Progress func(Progress)
+}
+
 // GetEbooks return books from the path.
 // If path is directory getEbook return all supported books.
 // If path is file getEbook return books or return error if filetype not supported.
 func GetEbooks(worker int, recursive bool, path string) ([]bookmanager.Book, error) {
-	info, err := os.Stat(path)
+	result, err := Find(path, FindOptions{Worker: worker, Recursive: recursive})
 	if err != nil {
 		return []bookmanager.Book{}, err
 	}
 
+	return result.Books, nil
+}
+
+// Find returns books from the path like GetEbooks, and the files of a
+// directory that were skipped.
+func Find(path string, opts FindOptions) (Result, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+
 	// Path is a file then filetype should support
 	// if not return error
 	if !info.IsDir() {
+		if opts.Skip != nil && opts.Skip(path) {
+			return Result{}, nil
+		}
 		bookInfo, err := bookparser.Parse(path)
 		if err != nil {
-			return []bookmanager.Book{}, err
+			return Result{}, err
 		}
 
-		book := bookmanager.NewBook(
-			bookInfo.Metadata.ISBN,
-			bookInfo.Metadata.Title,
-			bookInfo.Metadata.Authors,
-			bookInfo.Metadata.Publisher,
-			bookInfo.Metadata.Tags,
-		)
-		book.AppendFiles(bookInfo.File.Path, bookInfo.File.Type)
-		return []bookmanager.Book{book}, nil
+		book := newBook(bookInfo)
+		return Result{Books: []bookmanager.Book{book}}, nil
 	}
 
 	collector := newCollector()
-	err = collector.getEbooks(worker, recursive, path)
+	collector.continueOnError = opts.ContinueOnError
+	collector.skip = opts.Skip
+	collector.progress = opts.Progress
+	err = collector.getEbooks(max(opts.Worker, 1), opts.Recursive, path)
 	if err != nil {
-		return []bookmanager.Book{}, err
+		return Result{}, err
 	}
+	sort.Strings(collector.unsupported)
+	sort.Slice(collector.failed, func(i, j int) bool {
+		return collector.failed[i].Path < collector.failed[j].Path
+	})
 
-	return collector.books, nil
+	return Result{Books: collector.books, Unsupported: collector.unsupported, Failed: collector.failed}, nil
 }
@@ -0,0 +1,175 @@
+package bookfinder
+
+import (
+	"ebmgo/bookmanager"
+	"ebmgo/bookparser"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sidecarName is the metadata file calibre writes in every book directory.
+const sidecarName = "metadata.opf"
+
+// GetLibraryBooks returns the books stored in an ebm directory.
+// Files with the same name but a different extension are formats of the
+// same book. Metadata of a sidecar OPF file, either {name}.opf or a
+// metadata.opf alone with the book in its directory, is preferred over
+// metadata embedded in the files. Files that cannot be parsed are reported
+// on stderr and skipped.
+func GetLibraryBooks(worker int, path string) ([]bookmanager.Book, error) {
+	var files []string
+	err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// skip the db and its backups
+		if filepath.Dir(filePath) == path && strings.HasPrefix(d.Name(), "ebm.db") {
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(filePath), ".opf") {
+			return nil
+		}
+		files = append(files, filePath)
+		return nil
+	})
+	if err != nil {
+		return []bookmanager.Book{}, err
+	}
+
+	parsed := parseFiles(max(worker, 1), files)
+
+	// Group formats by file name without extension
+	groups := make(map[string][]bookparser.BookParser)
+	var stems []string
+	stemsInDir := make(map[string]int)
+	for _, filePath := range files {
+		f, ok := parsed[filePath]
+		if !ok {
+			continue
+		}
+		stem := strings.TrimSuffix(filePath, bookmanager.FileExt(filePath))
+		if _, found := groups[stem]; !found {
+			stems = append(stems, stem)
+			stemsInDir[filepath.Dir(stem)]++
+		}
+		groups[stem] = append(groups[stem], f)
+	}
+	sort.Strings(stems)
+
+	books := make([]bookmanager.Book, 0, len(stems))
+	for _, stem := range stems {
+		formats := groups[stem]
+		book := newBook(formats[0])
+		for _, f := range formats[1:] {
+			book.AppendFiles(f.File.Path, f.File.Type)
+		}
+
+		sidecar := stem + ".opf"
+		if _, err := os.Stat(sidecar); err != nil && stemsInDir[filepath.Dir(stem)] == 1 {
+			sidecar = filepath.Join(filepath.Dir(stem), sidecarName)
+		}
+		if _, err := os.Stat(sidecar); err == nil {
+			metadata, err := bookparser.ParseOPF(sidecar)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skip sidecar %s: %v\n", sidecar, err)
+			} else {
+				book = applySidecar(book, metadata)
+			}
+		}
+
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// parseFiles parses files concurrently, unsupported files are left out.
+func parseFiles(worker int, files []string) map[string]bookparser.BookParser {
+	parsed := make(map[string]bookparser.BookParser, len(files))
+	jobs := make(chan string)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < worker; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				f, err := bookparser.Parse(filePath)
+				if err != nil {
+					if err != bookparser.ErrNotSupportMimeType {
+						fmt.Fprintf(os.Stderr, "skip %s: %v\n", filePath, err)
+					}
+					continue
+				}
+				mu.Lock()
+				parsed[filePath] = f
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, filePath := range files {
+		jobs <- filePath
+	}
+	close(jobs)
+	wg.Wait()
+
+	return parsed
+}
+
+// applySidecar returns book with the non empty fields of metadata.
+func applySidecar(book bookmanager.Book, metadata bookparser.Metadata) bookmanager.Book {
+	isbn, title, authors, publisher, tags := book.ISBN, book.Title, book.Authors, book.Publisher, book.Tags
+	if metadata.ISBN != "" {
+		isbn = metadata.ISBN
+	}
+	if metadata.Title != "" {
+		title = metadata.Title
+	}
+	if len(metadata.Authors) > 0 {
+		authors = metadata.Authors
+	}
+	if metadata.Publisher != "" {
+		publisher = metadata.Publisher
+	}
+	if len(metadata.Tags) > 0 {
+		tags = metadata.Tags
+	}
+
+	b := bookmanager.NewBook(isbn, title, authors, publisher, tags)
+	b.ASIN = book.ASIN
+	b.Language = book.Language
+	b.Description = book.Description
+	b.PublishDate = book.PublishDate
+	b.Series = book.Series
+	b.SeriesIndex = book.SeriesIndex
+	b.Cover = book.Cover
+	if metadata.ASIN != "" {
+		b.ASIN = metadata.ASIN
+	}
+	if metadata.Language != "" {
+		b.Language = metadata.Language
+	}
+	if metadata.Description != "" {
+		b.Description = metadata.Description
+	}
+	if metadata.PublishDate != "" {
+		b.PublishDate = metadata.PublishDate
+	}
+	if metadata.Series != "" {
+		b.Series = metadata.Series
+		b.SeriesIndex = metadata.SeriesIndex
+	}
+	for _, file := range book.BookFiles {
+		b.AppendFiles(file.FilePath, file.FileType)
+	}
+
+	return b
+}
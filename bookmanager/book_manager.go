@@ -2,33 +2,53 @@ package bookmanager
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
 // Book is a single/unique book identity. It has many bookfiles to store different book format.
 type Book struct {
 	ID           int
 	ISBN         string
+	ASIN         string // Amazon identifier of Kindle books
 	Title        string
 	Authors      []string
 	uniqueAuthor map[string]bool
 	Publisher    string
+	Language     string
+	Description  string
+	PublishDate  string
+	Series       string
+	SeriesIndex  float64
 	Tags         []string
 	uniqueTag    map[string]bool
 	BookFiles    []BookFiles
 	uniqueFile   map[string]bool
+	Cover        string // image in the first book file used as cover, see ImportOptions.ReadCover
+	CreateDate   time.Time
+	ModifiedDate time.Time
 }
 
 // AppendFiles appends file to books.
 func (b *Book) AppendFiles(filePath string, fileType string) {
-	if !b.uniqueFile[filePath] {
-		b.BookFiles = append(b.BookFiles, BookFiles{FilePath: filePath, FileType: fileType})
-		b.uniqueFile[filePath] = true
+	b.appendFile(BookFiles{FilePath: filePath, FileType: fileType})
+}
+
+func (b *Book) appendFile(file BookFiles) {
+	if !b.uniqueFile[file.FilePath] {
+		b.BookFiles = append(b.BookFiles, file)
+		b.uniqueFile[file.FilePath] = true
 	}
 }
 
@@ -40,16 +60,32 @@ func (b *Book) AppendAuthors(author string) {
 }
 
 func (b *Book) AppendTag(tag string) {
-	if !b.uniqueAuthor[tag] {
+	if !b.uniqueTag[tag] {
 		b.Tags = append(b.Tags, tag)
 		b.uniqueTag[tag] = true
 	}
 }
 
+// copyMetadata returns a new book with the metadata of b and without book files.
+func (b *Book) copyMetadata() Book {
+	book := NewBook(b.ISBN, b.Title, b.Authors, b.Publisher, b.Tags)
+	book.ID = b.ID
+	book.ASIN = b.ASIN
+	book.Language = b.Language
+	book.Description = b.Description
+	book.PublishDate = b.PublishDate
+	book.Series = b.Series
+	book.SeriesIndex = b.SeriesIndex
+	book.Cover = b.Cover
+
+	return book
+}
+
 // BookFiles is a book file with specific filepath and filetype.
 type BookFiles struct {
 	FilePath string
 	FileType string
+	Checksum string // hex encoded SHA-256 of the file content
 }
 
 // NewBook return new instance of book.
@@ -79,18 +115,48 @@ func NewBook(isbn string, title string, authors []string, publisher string, tags
 
 // BookManager manage books by storing it to ebm dir and db.
 type BookManager struct {
-	repo      *repository
-	directory string
+	repo         *repository
+	directory    string
+	pathTemplate *template.Template
+	sanitize     SanitizeProfile
+	progress     ProgressFunc
+}
+
+// Options configures a book manager.
+type Options struct {
+	// PathTemplate is the Go template of book file paths relative to the
+	// ebm directory, without extension. DefaultPathTemplate if empty.
+	PathTemplate string
+	// Sanitize is the profile of file and directory names, SanitizePosix
+	// if empty.
+	Sanitize SanitizeProfile
+	// ReadOnly opens the db without creating or migrating it, for PlanImport.
+	// A library without db is treated as empty.
+	ReadOnly bool
 }
 
 // NewBookManage return instance of book manager.
-func NewBookManager(ebmDir string) (*BookManager, error) {
-	db, err := newSqliteConnection(ebmDir)
+func NewBookManager(ebmDir string, opts Options) (*BookManager, error) {
+	tmpl, err := parsePathTemplate(opts.PathTemplate)
+	if err != nil {
+		return nil, err
+	}
+	sanitize := opts.Sanitize
+	if sanitize == "" {
+		sanitize = SanitizePosix
+	}
+
+	var db *sql.DB
+	if opts.ReadOnly {
+		db, err = openSqliteReadOnly(filepath.Join(ebmDir, "ebm.db"))
+	} else {
+		db, err = newSqliteConnection(ebmDir)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed connect sqlite: %v", err)
 	}
 	repo := newRepository(db)
-	return &BookManager{repo: repo, directory: ebmDir}, nil
+	return &BookManager{repo: repo, directory: ebmDir, pathTemplate: tmpl, sanitize: sanitize}, nil
 }
 
 func (b *BookManager) Close() {
@@ -98,92 +164,309 @@ func (b *BookManager) Close() {
 }
 
 type processBookResult struct {
-	book *Book
-	err  error
+	source    *Book // book to import
+	book      *Book // imported book
+	transfers []transfer
+	cover     string // cover written next to the files
+	err       error
 }
 
-// processBookToEBMDir copies books from the source directory to the EBM directory.
-func (b *BookManager) processBookToEBMDir(book *Book, result chan<- processBookResult) {
-	// create folder to store a book
-	authors := "Unknown"
-	if len(book.Authors) > 0 {
-		authors = strings.Join(book.Authors, ",")
+// authorsName returns the authors part used in book directory and file names.
+func authorsName(book *Book) string {
+	if len(book.Authors) == 0 {
+		return "Unknown"
 	}
-	path := filepath.Join(b.directory, authors, book.Title)
-	if err := os.MkdirAll(path, 0750); err != nil {
-		result <- processBookResult{
-			book: nil,
-			err:  err,
+	return strings.Join(book.Authors, ",")
+}
+
+// compoundExts are extensions of files that are compressed as a whole, which
+// are kept in file names, e.g. "Title (2).fb2.zip".
+var compoundExts = []string{".fb2.zip"}
+
+// FileExt returns the extension of a book file, including the extension of
+// the compressed format for compound extensions.
+func FileExt(path string) string {
+	for _, ext := range compoundExts {
+		if len(path) > len(ext) && strings.EqualFold(path[len(path)-len(ext):], ext) {
+			return path[len(path)-len(ext):]
 		}
-		return
 	}
+	return filepath.Ext(path)
+}
+
+// bookFilePath returns the path of a book file with the given extension,
+// given by the path template.
+func (b *BookManager) bookFilePath(book *Book, ext string) (string, error) {
+	path, err := executePathTemplate(b.pathTemplate, b.sanitize, book, ext)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(b.directory, path), nil
+}
 
-	newBook := NewBook(book.ISBN, book.Title, book.Authors, book.Publisher, book.Tags)
-	for j, file := range book.BookFiles {
-		filename := fmt.Sprintf("%s - %s%s", book.Title, authors, filepath.Ext(file.FilePath))
-		destPath := filepath.Join(path, filename)
+// copyFileWithChecksum copies src to dst and returns the checksum of the copied content.
+func copyFileWithChecksum(src, dst string) (string, error) {
+	srcFile, err := os.Open(src) // Source file to copy
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
 
-		// if already exist, skip
-		if _, err := os.Stat(destPath); !os.IsNotExist(err) {
-			continue
+	destFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644) // Destination to paste
+	if err != nil {
+		return "", err
+	}
+	defer destFile.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(destFile, h), srcFile); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileExists reports whether a file exists at path. Paths that can not be
+// checked are reported as missing, creating them fails with the actual error.
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// sameContent reports whether the file at path has the given checksum.
+func sameContent(path string, checksum string) bool {
+	if checksum == "" {
+		return false
+	}
+	c, err := fileChecksum(path)
+	return err == nil && c == checksum
+}
+
+// processBookToEBMDir copies, moves or links books from the source directory
+// to the EBM directory, as set by opts.Mode.
+func (b *BookManager) processBookToEBMDir(book *Book, opts ImportOptions, progress *progressTracker, result chan<- processBookResult) {
+	newBook := book.copyMetadata()
+	var transfers []transfer
+	fail := func(err error) {
+		// revert files transferred before the error
+		undoTransfers(transfers)
+		for _, t := range transfers {
+			b.removeEmptyDirs(filepath.Dir(t.dst))
+		}
+		result <- processBookResult{
+			source: book,
+			book:   nil,
+			err:    err,
 		}
-		// Copy and paste file
-		// Update books[i].Path
-		srcFile, err := os.Open(file.FilePath) // Source file to copy
+	}
+	for _, file := range book.BookFiles {
+		destPath, err := b.bookFilePath(book, FileExt(file.FilePath))
 		if err != nil {
-			result <- processBookResult{
-				book: nil,
-				err:  err,
-			}
+			fail(err)
 			return
 		}
-		defer srcFile.Close()
-		destFile, err := os.Create(destPath) // Destination to paste
+
+		// Transfer file, with a collision number if the path is taken
+		size := fileSize(file.FilePath) // before it is moved
+		t, checksum, err := b.transferFileUnique(file.FilePath, destPath, opts)
 		if err != nil {
-			result <- processBookResult{
-				book: nil,
-				err:  err,
+			if t.dst != "" {
+				b.removeEmptyDirs(filepath.Dir(t.dst))
 			}
+			fail(err)
 			return
 		}
-		defer destFile.Close()
-		if _, err := io.Copy(destFile, srcFile); err != nil {
-			result <- processBookResult{
-				book: nil,
-				err:  err,
-			}
+		transfers = append(transfers, t)
+		progress.add(file.FilePath, size)
+		if checksum == "" {
+			// not read by links and moves
+			checksum = file.Checksum
+		}
+		newBook.appendFile(BookFiles{FilePath: t.dst, FileType: file.FileType, Checksum: checksum})
+		if file.Checksum != "" && checksum != file.Checksum {
+			fail(fmt.Errorf("%s changed during import", file.FilePath))
 			return
 		}
-		book.BookFiles[j].FilePath = destPath
-		newBook.AppendFiles(book.BookFiles[j].FilePath, book.BookFiles[j].FileType)
 	}
 	if len(newBook.BookFiles) > 0 {
+		cover, err := writeCover(book, newBook.BookFiles[0].FilePath, opts)
+		if err != nil {
+			fail(err)
+			return
+		}
 		// insert bookfiles
 		result <- processBookResult{
-			book: &newBook,
-			err:  nil,
+			source:    book,
+			book:      &newBook,
+			transfers: transfers,
+			cover:     cover,
+			err:       nil,
 		}
 	}
 }
 
-// ImportBooks copies books from the source directory to the EBM directory
-// and inserts metadata into the database.
+// DefaultImportBatchSize is the number of books ImportBooks imports per
+// transaction when ImportOptions.BatchSize is not set.
+const DefaultImportBatchSize = 100
+
+// ImportOptions controls how ImportBooks imports books.
+type ImportOptions struct {
+	// Worker is the number of books copied concurrently.
+	Worker int
+	// OnDuplicate is the policy for files whose content is already in the
+	// library or in the same import, DuplicateSkip by default.
+	OnDuplicate DuplicatePolicy
+	// Ask decides the policy for a duplicate when OnDuplicate is DuplicateAsk.
+	// It must return DuplicateSkip, DuplicateAddFormat or DuplicateNewBook.
+	Ask func(dup Duplicate) DuplicatePolicy
+	// Mode is how files are put into the ebm directory, TransferCopy by default.
+	Mode TransferMode
+	// Fallback is the mode used when a hardlink or reflink is not possible,
+	// e.g. across file systems. TransferCopy by default.
+	Fallback TransferMode
+	// ContinueOnError imports the other books when files of a book can not
+	// be read or transferred, the book is reported as failed. Otherwise
+	// the import stops at the first error.
+	ContinueOnError bool
+	// BatchSize is the number of books imported per transaction,
+	// DefaultImportBatchSize if 0.
+	BatchSize int
+	// Run is the journal the outcome of every file is written to, see
+	// StartImport. Nothing is journaled if nil.
+	Run *ImportRun
+	// SaveCovers writes the cover of a book as an image next to its first
+	// imported file, e.g. "Title - Author.jpg". Without it the cover is only
+	// referenced by Book.Cover.
+	SaveCovers bool
+	// ReadCover returns the image Book.Cover of the first file of a book,
+	// which is written by SaveCovers. Without it, or when the book has no
+	// cover, an image named like the first file is used, as written by
+	// earlier imports.
+	ReadCover func(file BookFiles, cover string) ([]byte, error)
+}
+
+// ImportBooks copies, moves or links books from the source directory to the
+// EBM directory and inserts metadata into the database.
 //
-// The EBM directory follows this structure:
+// Files are stored at the path given by the path template, by default:
 //
 //	ebm-dir/{book.authors}/{book.title}/{book.title} - {book-author}.{ext}
 //
+// The SHA-256 checksum of every file is stored, files whose content is
+// already in the library are handled by opts.OnDuplicate.
+//
+// Books are imported in batches of opts.BatchSize, each batch in its own
+// transaction. If anything fails in a batch, its moved files are moved back
+// and its other files are removed, earlier batches stay imported. Sources of
+// files moved across file systems are only removed once the batch is
+// committed.
+//
 // Parameters:
 //
 //	books []Book - List of books to be imported.
+//	opts ImportOptions - Worker count, duplicate policy, transfer mode and journal.
 //
 // Returns:
 //
+//	ImportReport - The outcome of every file of the committed batches.
 //	error - An error if any operation fails.
-func (b *BookManager) ImportBooks(ctx context.Context, worker int, books []Book) error {
+func (b *BookManager) ImportBooks(ctx context.Context, books []Book, opts ImportOptions) (ImportReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = TransferCopy
+	}
+	if opts.Fallback == "" {
+		opts.Fallback = TransferCopy
+	}
+	if opts.Fallback != TransferCopy && opts.Fallback != TransferSymlink {
+		return ImportReport{}, fmt.Errorf("link fallback must be copy or symlink, got %s", opts.Fallback)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	items, duplicates, failed, err := b.planImport(ctx, books, opts)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	var report ImportReport
+	for _, dup := range duplicates {
+		report.Files = append(report.Files, duplicateResult(dup))
+	}
+	report.Files = append(report.Files, failed...)
+	if opts.Run != nil {
+		if err := b.repo.appendJournal(ctx, opts.Run.ID, report.Files); err != nil {
+			return ImportReport{}, err
+		}
+	}
+
+	planned := make([]Book, 0, len(items))
+	for _, item := range items {
+		planned = append(planned, item.book)
+	}
+	progress := b.track(StageTransfer, planned)
+
+	for start := 0; start < len(items); start += batchSize {
+		batch := items[start:min(start+batchSize, len(items))]
+		results, err := b.importBatch(ctx, batch, opts, progress)
+		if err != nil {
+			return report, err
+		}
+		report.Files = append(report.Files, results...)
+	}
+
+	if opts.Run != nil {
+		if err := b.repo.finishImportRun(opts.Run.ID); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// importBatch transfers files of items to the ebm directory and inserts
+// them in one transaction, and returns the outcome of every file.
+func (b *BookManager) importBatch(ctx context.Context, items []importItem, opts ImportOptions, progress *progressTracker) ([]FileResult, error) {
+	worker := max(opts.Worker, 1)
+	runID := 0
+	if opts.Run != nil {
+		runID = opts.Run.ID
+	}
+
 	insertBook := []*Book{} // metadata to store
-	if err := b.repo.CreateBooks(
+	var transfers []transfer
+	var covers []string
+	var failed []FileResult
+	type importedBook struct {
+		book      *Book
+		transfers []transfer
+		merged    bool // added to a book already in the library
+	}
+	var imported []importedBook
+	results := func() []FileResult {
+		var results []FileResult
+		for _, book := range imported {
+			outcome := OutcomeImported
+			if book.merged {
+				outcome = OutcomeMerged
+			}
+			for _, t := range book.transfers {
+				results = append(results, FileResult{
+					Path:        t.src,
+					Outcome:     outcome,
+					BookID:      book.book.ID,
+					Destination: t.dst,
+				})
+			}
+		}
+		return append(results, failed...)
+	}
+
+	if err := b.repo.createBooks(
 		ctx,
+		runID,
 		func() ([]*Book, error) {
 
 			jobs := make(chan *Book)
@@ -202,19 +485,19 @@ func (b *BookManager) ImportBooks(ctx context.Context, worker int, books []Book)
 							if !ok {
 								return
 							}
-							b.processBookToEBMDir(book, result)
+							b.processBookToEBMDir(book, opts, progress, result)
 						}
 					}
 				}()
 			}
 
 			go func() {
-				for _, book := range books {
+				for i := range items {
 					select {
 					case <-ctx.Done():
 						close(jobs)
 						return
-					case jobs <- &book:
+					case jobs <- &items[i].book:
 					}
 				}
 				close(jobs)
@@ -227,27 +510,54 @@ func (b *BookManager) ImportBooks(ctx context.Context, worker int, books []Book)
 
 			var err error
 			for res := range result {
+				if res.err != nil && opts.ContinueOnError {
+					for _, file := range res.source.BookFiles {
+						failed = append(failed, failedResult(file.FilePath, res.err))
+					}
+					continue
+				}
 				if res.err != nil {
 					err = res.err
+					continue
 				}
 
 				insertBook = append(insertBook, res.book)
+				transfers = append(transfers, res.transfers...)
+				if res.cover != "" {
+					covers = append(covers, res.cover)
+				}
+				imported = append(imported, importedBook{
+					book:      res.book,
+					transfers: res.transfers,
+					merged:    res.book.ID != 0,
+				})
 			}
 
 			return insertBook, err
 		},
+		results,
 		func() {
-			for _, book := range insertBook {
-				for _, file := range book.BookFiles {
-					os.Remove(file.FilePath)
-				}
+			for _, cover := range covers {
+				os.Remove(cover)
+			}
+			undoTransfers(transfers)
+			for _, t := range transfers {
+				b.removeEmptyDirs(filepath.Dir(t.dst))
 			}
 		},
 	); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	for _, t := range transfers {
+		if t.removeSource {
+			if err := os.Remove(t.src); err != nil {
+				fmt.Fprintf(os.Stderr, "failed remove moved file %s: %v\n", t.src, err)
+			}
+		}
+	}
+
+	return results(), nil
 }
 
 func getFilename(filepath string) string {
@@ -265,112 +575,249 @@ func getFilename(filepath string) string {
 	return ""
 }
 
-func (h *BookManager) Export(ids []int, dstPath string) error {
+// Export copies files of books to dstPath. File names are sanitized with
+// profile, or the profile of the book manager if empty. A file already in
+// dstPath with the same content is skipped, other files with the same name
+// get a collision number.
+func (h *BookManager) Export(ids []int, dstPath string, profile SanitizeProfile) error {
 	books, err := h.repo.getBooks(ids)
 	if err != nil {
 		return err
 	}
+	if profile == "" {
+		profile = h.sanitize
+	}
 
 	if err := os.MkdirAll(dstPath, 0750); err != nil {
 		return err
 	}
 
+	progress := h.track(StageExport, books)
 	for _, book := range books {
 		for _, file := range book.BookFiles {
-			dst := filepath.Join(dstPath, getFilename(file.FilePath))
-			if err := copyFileContents(file.FilePath, dst); err != nil {
+			dst, err := profile.uniquePath(dstPath, filepath.Join(dstPath, getFilename(file.FilePath)), func(path string) bool {
+				return fileExists(path) && !sameContent(path, file.Checksum)
+			})
+			if err != nil {
+				return err
+			}
+			if sameContent(dst, file.Checksum) {
+				// already exported
+				progress.add(file.FilePath, fileSize(file.FilePath))
+				continue
+			}
+			if _, err := copyFileWithChecksum(file.FilePath, dst); err != nil {
 				fmt.Println("copy file dst to", dst, "error:", err)
 			}
+			progress.add(file.FilePath, fileSize(file.FilePath))
 		}
 	}
 
 	return nil
 }
 
-func (b *BookManager) GetBooks(pattern string) ([]Book, error) {
-	return b.repo.FindBooks(pattern)
+// Sort orders of GetBooks.
+const (
+	SortByID       = "id"
+	SortByTitle    = "title"
+	SortByAuthor   = "author"
+	SortByAdded    = "added"
+	SortByModified = "modified"
+	SortBySeries   = "series" // by series then series index
+)
+
+// QueryOptions controls the order and the page of books returned by GetBooks.
+type QueryOptions struct {
+	Sort   string // one of the Sort constants, SortByID by default
+	Desc   bool
+	Limit  int // maximum number of books, 0 for no limit
+	Offset int // number of books to skip
+}
+
+// GetBooks returns books matching the search query.
+// The query is parsed by the search query language, e.g.
+//
+//	author:tolkien tag:fantasy format:epub -tag:unread title:"ring*"
+//
+// An invalid query returns a *QueryError.
+func (b *BookManager) GetBooks(pattern string, opts QueryOptions) ([]Book, error) {
+	return b.repo.FindBooks(pattern, opts)
+}
+
+// GetBooksByIDs returns books with the given ids.
+func (b *BookManager) GetBooksByIDs(ids []int) ([]Book, error) {
+	return b.repo.getBooks(ids)
 }
 
+// RemoveBooks removes books and their files. Files are renamed into a
+// temporary directory of the ebm directory until the books are removed from
+// the db, and renamed back if that fails, so symbolic links stay links.
 func (b *BookManager) RemoveBooks(ids []int) error {
-	var tmpFile []string
+	tmpDir, err := os.MkdirTemp(b.directory, ".ebmgo-remove-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var moved []fileMove
 	if err := b.repo.RemoveBooks(
 		ids,
 		func(books []Book) error {
+			var files []string // book files and their covers
 			for _, book := range books {
 				for _, file := range book.BookFiles {
-					// move files to /tmp directory
-					newPath := strings.ReplaceAll(file.FilePath, b.directory, os.TempDir())
-					err := moveFile(file.FilePath, newPath)
-					if err != nil {
-						return fmt.Errorf("move failed: %v", err)
+					files = append(files, file.FilePath)
+					if cover := coverPath(file.FilePath); cover != "" && !slices.Contains(files, cover) {
+						files = append(files, cover)
 					}
-					tmpFile = append(tmpFile, newPath)
 				}
 			}
+			for i, filePath := range files {
+				newPath := filepath.Join(tmpDir, strconv.Itoa(i))
+				if err := os.Rename(filePath, newPath); err != nil {
+					return fmt.Errorf("move failed: %v", err)
+				}
+				moved = append(moved, fileMove{from: filePath, to: newPath})
+			}
 			return nil
 		},
 		func() {
-			for _, f := range tmpFile {
-				newPath := strings.ReplaceAll(f, os.TempDir(), b.directory)
-				copyFileContents(f, newPath)
+			for i := len(moved) - 1; i >= 0; i-- {
+				os.Rename(moved[i].to, moved[i].from)
 			}
 		},
 	); err != nil {
 		return err
 	}
 
-	for _, f := range tmpFile {
-		os.Remove(f)
+	for _, move := range moved {
+		b.removeEmptyDirs(filepath.Dir(move.from))
 	}
 
 	return nil
 }
 
-func moveFile(src, dst string) error {
-	// Ensure parent directories exist
-	parentDir := filepath.Dir(dst)
-	err := os.MkdirAll(parentDir, os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("failed to create parent directory: %v", err)
-	}
+// fileMove is a book file renamed from one path to another.
+type fileMove struct {
+	bookID int
+	from   string
+	to     string
+}
 
-	err = copyFileContents(src, dst)
-	if err != nil {
-		return fmt.Errorf("copy failed: %v", err)
+// bookChanged reports whether metadata stored in the db differs between books.
+func bookChanged(a, b *Book) bool {
+	return a.Title != b.Title ||
+		a.ISBN != b.ISBN ||
+		a.ASIN != b.ASIN ||
+		a.Publisher != b.Publisher ||
+		a.Language != b.Language ||
+		a.Description != b.Description ||
+		a.PublishDate != b.PublishDate ||
+		a.Series != b.Series ||
+		a.SeriesIndex != b.SeriesIndex ||
+		strings.Join(a.Authors, "\x00") != strings.Join(b.Authors, "\x00") ||
+		strings.Join(a.Tags, "\x00") != strings.Join(b.Tags, "\x00")
+}
+
+// UpdateBooks stores edited metadata of already imported books.
+// Books are matched to the library by ID, each book once, book files can
+// not be changed.
+// Files of books whose title or authors changed are moved to the directory
+// matching the new metadata.
+//
+// Returns the number of books that were changed.
+func (b *BookManager) UpdateBooks(ctx context.Context, books []Book) (int, error) {
+	ids := make([]int, 0, len(books))
+	seen := make(map[int]bool, len(books))
+	for _, book := range books {
+		if seen[book.ID] {
+			return 0, fmt.Errorf("book %d is given more than once", book.ID)
+		}
+		seen[book.ID] = true
+		ids = append(ids, book.ID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
 	}
 
-	// Remove original file
-	err = os.Remove(src)
+	stored, err := b.repo.getBooks(ids)
 	if err != nil {
-		return fmt.Errorf("remove original failed: %v", err)
+		return 0, err
+	}
+	storedByID := make(map[int]*Book, len(stored))
+	for i := range stored {
+		storedByID[stored[i].ID] = &stored[i]
 	}
-	return nil
 
-}
+	var updates []*Book
+	var moves []fileMove
+	targets := make(map[string]bool) // new paths, files of the same path get a collision number
+	for _, edited := range books {
+		old, ok := storedByID[edited.ID]
+		if !ok {
+			return 0, fmt.Errorf("book %d not found", edited.ID)
+		}
 
-func copyFileContents(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
+		book := edited.copyMetadata()
+		if book.Title == "" {
+			return 0, fmt.Errorf("book %d: title is required", book.ID)
+		}
+		if !bookChanged(old, &book) {
+			continue
+		}
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+		for _, file := range old.BookFiles {
+			newPath, err := b.bookFilePath(&book, FileExt(file.FilePath))
+			if err != nil {
+				return 0, err
+			}
+			newPath, err = b.sanitize.uniquePath(b.directory, newPath, func(path string) bool {
+				return path != file.FilePath && (targets[path] || fileExists(path))
+			})
+			if err != nil {
+				return 0, err
+			}
+			targets[newPath] = true
+			if newPath != file.FilePath {
+				moves = append(moves, fileMove{bookID: book.ID, from: file.FilePath, to: newPath})
+			}
+			book.AppendFiles(newPath, file.FileType)
+		}
+		updates = append(updates, &book)
+	}
+	if len(updates) == 0 {
+		return 0, nil
 	}
-	defer destFile.Close()
 
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
+	var moved []fileMove
+	if err := b.repo.UpdateBooks(
+		ctx,
+		updates,
+		moves,
+		func() error {
+			moved, err = renameFiles(withCovers(moves))
+			return err
+		},
+		func() {
+			b.revertMoves(moved)
+		},
+	); err != nil {
+		return 0, err
 	}
 
-	// Optional: Copy file permissions
-	info, err := sourceFile.Stat()
-	if err == nil {
-		err = os.Chmod(dst, info.Mode())
+	for _, move := range moves {
+		b.removeEmptyDirs(filepath.Dir(move.from))
 	}
 
-	return err
+	return len(updates), nil
+}
+
+// removeEmptyDirs removes dir and its parents up to the ebm directory while they are empty.
+func (b *BookManager) removeEmptyDirs(dir string) {
+	for dir != b.directory && strings.HasPrefix(dir, b.directory) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
 }
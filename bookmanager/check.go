@@ -0,0 +1,188 @@
+package bookmanager
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of problems reported by Check.
+const (
+	ProblemMissingFile      = "missing-file"       // BookFiles row without a file
+	ProblemOrphanFile       = "orphan-file"        // file in the ebm directory unknown to the db
+	ProblemOrphanAuthor     = "orphan-author"      // author without books
+	ProblemOrphanTag        = "orphan-tag"         // tag without books
+	ProblemOrphanSeries     = "orphan-series"      // series without books
+	ProblemFtsOutOfSync     = "fts-out-of-sync"    // BooksFts row missing or different from Books
+	ProblemBookWithoutFiles = "book-without-files" // book without BookFiles rows
+	ProblemChecksumMismatch = "checksum-mismatch"  // file content differs from stored checksum
+	ProblemMissingChecksum  = "missing-checksum"   // file imported before checksums were stored
+	ProblemUnreadableFile   = "unreadable-file"    // file that can not be read to verify it
+)
+
+// Problem is an inconsistency between the db and the ebm directory.
+type Problem struct {
+	Kind   string
+	BookID int    // 0 if not related to a book
+	Value  string // file path, author, tag or series
+	Fixed  bool
+}
+
+// CheckOptions controls Check.
+type CheckOptions struct {
+	// Fix repairs problems that can be repaired without losing data:
+	// orphan authors, tags and series, the full text index and missing
+	// checksums. Missing files, orphan files, books without files,
+	// unreadable files and checksum mismatches are only reported.
+	Fix bool
+	// PruneMissing removes the rows of missing files. A missing file may
+	// only be out of reach, e.g. the source of a symlinked book on an
+	// unmounted drive, so its book loses the file for good.
+	PruneMissing bool
+	// SkipChecksums does not read files to verify their checksum.
+	SkipChecksums bool
+}
+
+// isLibraryFile reports whether a file in the root of the ebm directory
+// belongs to ebm itself, like the db and its backups.
+func isLibraryFile(name string) bool {
+	return strings.HasPrefix(name, "ebm.db")
+}
+
+// Check verifies that the db and the files in the ebm directory agree.
+func (b *BookManager) Check(ctx context.Context, opts CheckOptions) ([]Problem, error) {
+	var problems []Problem
+
+	files, err := b.repo.allFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(files))
+	knownStems := make(map[string]bool, len(files)) // covers are named like the book files
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		known[file.filePath] = true
+		knownStems[coverStem(file.filePath)] = true
+
+		if _, err := os.Stat(file.filePath); os.IsNotExist(err) {
+			p := Problem{Kind: ProblemMissingFile, BookID: file.bookID, Value: file.filePath}
+			if opts.PruneMissing {
+				if err := b.repo.deleteFile(file.bookID, file.filePath); err != nil {
+					return nil, err
+				}
+				p.Fixed = true
+			}
+			problems = append(problems, p)
+			continue
+		} else if err != nil {
+			problems = append(problems, Problem{Kind: ProblemUnreadableFile, BookID: file.bookID, Value: file.filePath})
+			continue
+		}
+
+		if opts.SkipChecksums {
+			continue
+		}
+		checksum, err := fileChecksum(file.filePath)
+		if err != nil {
+			problems = append(problems, Problem{Kind: ProblemUnreadableFile, BookID: file.bookID, Value: file.filePath})
+			continue
+		}
+		switch file.checksum {
+		case checksum:
+		case "":
+			p := Problem{Kind: ProblemMissingChecksum, BookID: file.bookID, Value: file.filePath}
+			if opts.Fix {
+				if err := b.repo.setChecksum(file.bookID, file.filePath, checksum); err != nil {
+					return nil, err
+				}
+				p.Fixed = true
+			}
+			problems = append(problems, p)
+		default:
+			problems = append(problems, Problem{Kind: ProblemChecksumMismatch, BookID: file.bookID, Value: file.filePath})
+		}
+	}
+
+	err = filepath.WalkDir(b.directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return ctx.Err()
+		}
+		if filepath.Dir(path) == b.directory && isLibraryFile(d.Name()) {
+			return nil
+		}
+		// sidecar metadata files are read by rebuild-db
+		if strings.EqualFold(filepath.Ext(path), ".opf") || isCover(path, knownStems) {
+			return nil
+		}
+		if !known[path] {
+			problems = append(problems, Problem{Kind: ProblemOrphanFile, Value: path})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []struct {
+		kind  string
+		query func() ([]string, error)
+	}{
+		{ProblemOrphanAuthor, b.repo.orphanAuthors},
+		{ProblemOrphanTag, b.repo.orphanTags},
+		{ProblemOrphanSeries, b.repo.orphanSeries},
+	}
+	for _, orphan := range orphans {
+		values, err := orphan.query()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			problems = append(problems, Problem{Kind: orphan.kind, Value: v, Fixed: opts.Fix})
+		}
+	}
+	if opts.Fix {
+		if err := b.repo.deleteOrphans(); err != nil {
+			return nil, err
+		}
+	}
+
+	ids, err := b.repo.booksWithoutFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		bookID, _ := strconv.Atoi(id)
+		problems = append(problems, Problem{Kind: ProblemBookWithoutFiles, BookID: bookID})
+	}
+
+	ids, err = b.repo.ftsOutOfSync()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	for _, id := range ids {
+		bookID, _ := strconv.Atoi(id)
+		problems = append(problems, Problem{Kind: ProblemFtsOutOfSync, BookID: bookID, Value: id, Fixed: opts.Fix})
+	}
+	if opts.Fix && len(ids) > 0 {
+		if err := b.repo.rebuildFts(); err != nil {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
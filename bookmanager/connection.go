@@ -10,22 +10,22 @@ import (
 )
 
 func newSqliteConnection(path string) (*sql.DB, error) {
-	migrate := false
-	// if path not exist, create path and do migate
+	// if path not exist, create path
 	if _, err := os.Stat(path); err != nil {
 		if err := os.MkdirAll(path, 0750); err != nil {
 			return nil, fmt.Errorf("create ebm directory: %v", err)
 		}
-		migrate = true
 	}
 
-	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?cache=shared&mode=rwc", filepath.Join(path, "ebm.db")))
-	if err != nil {
-		return nil, err
-	}
+	return openSqlite(filepath.Join(path, "ebm.db"))
+}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON;"); err != nil {
+// openSqlite opens the db at dbPath and migrates it to the latest schema.
+func openSqlite(dbPath string) (*sql.DB, error) {
+	// Foreign keys are enabled in the DSN, so that every connection of the
+	// pool enforces them, not only the one running a PRAGMA
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?cache=shared&mode=rwc&_foreign_keys=on", dbPath))
+	if err != nil {
 		return nil, err
 	}
 
@@ -39,28 +39,87 @@ func newSqliteConnection(path string) (*sql.DB, error) {
 		return nil, errors.New("foreign keys are disable")
 	}
 
-	if migrate {
-		if err := runMigration(db, "sql/schema.sql"); err != nil {
-			return nil, err
-		}
+	if err := checkFts5(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := runMigrations(db, dbPath); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// openSqliteReadOnly opens the db at dbPath without changing it. A missing db
+// is replaced by an empty in-memory db, so nothing is created.
+func openSqliteReadOnly(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return openMemorySqlite()
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", dbPath))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current != len(migrations) {
+		db.Close()
+		return nil, fmt.Errorf("database schema version %d is not the supported version %d, open the library once to migrate it", current, len(migrations))
 	}
 
 	return db, nil
 }
 
-func runMigration(db *sql.DB, filepath string) error {
-	// Read schema.sql file
-	sqlBytes, err := os.ReadFile(filepath)
+// openMemorySqlite returns an empty in-memory db with the latest schema.
+func openMemorySqlite() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file::memory:")
 	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+		return nil, err
 	}
+	// Every connection would get its own in-memory db
+	db.SetMaxOpenConns(1)
 
-	// Execute SQL
-	_, err = db.Exec(string(sqlBytes))
+	if err := checkFts5(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	migrations, err := loadMigrations()
 	if err != nil {
-		return fmt.Errorf("failed to apply migration: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	for _, m := range migrations {
+		if err := applyMigration(db, m); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
-	fmt.Println("Migration applied successfully.")
+	return db, nil
+}
+
+// checkFts5 returns an error if sqlite lacks FTS5, which the migrations and
+// search queries need for BooksFts.
+func checkFts5(db *sql.DB) error {
+	var enabled bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("sqlite is built without full text search, build ebm with -tags sqlite_fts5")
+	}
 	return nil
 }
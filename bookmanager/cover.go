@@ -0,0 +1,127 @@
+package bookmanager
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Covers are stored next to the book files, named like the book file with
+// the extension of the image, e.g. "Title - Author.jpg". They move and are
+// removed with the book file of the same name.
+
+// coverTypes are the extensions of cover images by content type.
+var coverTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// coverExts are the extensions of cover images.
+var coverExts = []string{".jpg", ".png", ".gif", ".webp", ".bmp"}
+
+// coverStem returns the path of a book file without its extension.
+func coverStem(filePath string) string {
+	return strings.TrimSuffix(filePath, FileExt(filePath))
+}
+
+// isCover reports whether path is the cover of a book file in known.
+func isCover(path string, knownStems map[string]bool) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range coverExts {
+		if ext == e {
+			return knownStems[strings.TrimSuffix(path, filepath.Ext(path))]
+		}
+	}
+	return false
+}
+
+// coverPath returns the path of the cover of a book file, empty if it has none.
+func coverPath(filePath string) string {
+	stem := coverStem(filePath)
+	for _, ext := range coverExts {
+		if fileExists(stem + ext) {
+			return stem + ext
+		}
+	}
+	return ""
+}
+
+// readCover returns the cover of a book to import: the image read by
+// opts.ReadCover, or the cover next to its first file, e.g. when books are
+// moved between libraries. nil if the book has no cover.
+func readCover(book *Book, opts ImportOptions) []byte {
+	if len(book.BookFiles) == 0 {
+		return nil
+	}
+	file := book.BookFiles[0]
+	if book.Cover != "" && opts.ReadCover != nil {
+		// a cover that can not be read is left out
+		if data, err := opts.ReadCover(file, book.Cover); err == nil {
+			return data
+		}
+	}
+	if cover := coverPath(file.FilePath); cover != "" {
+		if data, err := os.ReadFile(cover); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// writeCover writes the cover of a book next to its imported file dst if
+// opts.SaveCovers is set, and returns its path. The path is empty if the book
+// has no cover, or if a cover of that name already exists, e.g. of a book the
+// files are added to.
+func writeCover(book *Book, dst string, opts ImportOptions) (string, error) {
+	if !opts.SaveCovers {
+		return "", nil
+	}
+	data := readCover(book, opts)
+	ext, ok := coverTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", nil
+	}
+	if cover := coverPath(dst); cover != "" {
+		return "", nil
+	}
+
+	path := coverStem(dst) + ext
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// withCovers returns moves and the moves of the covers of the moved files.
+func withCovers(moves []fileMove) []fileMove {
+	all := append([]fileMove{}, moves...)
+	seen := make(map[string]bool)
+	for _, move := range moves {
+		cover := coverPath(move.from)
+		if cover == "" || seen[cover] {
+			continue
+		}
+		seen[cover] = true
+		if to := coverStem(move.to) + filepath.Ext(cover); to != cover {
+			all = append(all, fileMove{bookID: move.bookID, from: cover, to: to})
+		}
+	}
+	return all
+}
@@ -0,0 +1,271 @@
+package bookmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DuplicatePolicy decides what ImportBooks does with a file whose content is
+// already in the library or earlier in the same import.
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip does not import the duplicate file.
+	DuplicateSkip DuplicatePolicy = "skip"
+	// DuplicateAddFormat does not import the duplicate file, other files of
+	// the book are added as formats of the book that has the duplicate.
+	DuplicateAddFormat DuplicatePolicy = "add-format"
+	// DuplicateNewBook imports the duplicate file as usual.
+	DuplicateNewBook DuplicatePolicy = "new-book"
+	// DuplicateAsk asks ImportOptions.Ask for every duplicate.
+	DuplicateAsk DuplicatePolicy = "ask"
+)
+
+// ParseDuplicatePolicy returns the duplicate policy with the given name.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(name); p {
+	case DuplicateSkip, DuplicateAddFormat, DuplicateNewBook, DuplicateAsk:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy: %s", name)
+	}
+}
+
+// Duplicate describes a file to import whose content is already known.
+type Duplicate struct {
+	FilePath     string // file to import
+	ExistingPath string // file with the same content
+	BookID       int    // book of the existing file, 0 if it is part of the same import
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of a file.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumFiles computes checksums of all files of books in place. With
+// skipErrors, files that can not be read keep an empty checksum and are
+// returned as failed instead of stopping at the first one. Every file is
+// counted by progress.
+func checksumFiles(ctx context.Context, worker int, books []Book, skipErrors bool, progress *progressTracker) ([]FileResult, error) {
+	if worker < 1 {
+		worker = 1
+	}
+
+	jobs := make(chan *BookFiles)
+	errs := make(chan error, worker)
+	var failed []FileResult
+	var mu sync.Mutex
+	wg := sync.WaitGroup{}
+	for i := 0; i < worker; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				checksum, err := fileChecksum(file.FilePath)
+				progress.add(file.FilePath, fileSize(file.FilePath))
+				if err != nil && skipErrors {
+					mu.Lock()
+					failed = append(failed, failedResult(file.FilePath, err))
+					mu.Unlock()
+					continue
+				}
+				if err != nil {
+					errs <- fmt.Errorf("checksum %s: %v", file.FilePath, err)
+					return
+				}
+				file.Checksum = checksum
+			}
+		}()
+	}
+
+	var err error
+send:
+	for i := range books {
+		for j := range books[i].BookFiles {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				break send
+			case err = <-errs:
+				break send
+			case jobs <- &books[i].BookFiles[j]:
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+
+	return failed, err
+}
+
+// importItem is a book to import. If bookID is set, files are added as new
+// formats of that book in the library.
+type importItem struct {
+	book   Book
+	bookID int
+}
+
+// batchTarget is a file earlier in the same import.
+type batchTarget struct {
+	item int
+	path string
+}
+
+// planImport computes file checksums and resolves duplicates according to
+// the import options, before anything is written. Duplicate files that are
+// not imported are returned with the items, and with opts.ContinueOnError
+// the files whose checksum failed.
+func (b *BookManager) planImport(ctx context.Context, books []Book, opts ImportOptions) ([]importItem, []Duplicate, []FileResult, error) {
+	policy := opts.OnDuplicate
+	if policy == "" {
+		policy = DuplicateSkip
+	}
+	if policy == DuplicateAsk && opts.Ask == nil {
+		return nil, nil, nil, fmt.Errorf("duplicate policy ask requires a callback")
+	}
+
+	// Work on a copy, the caller keeps the source file paths.
+	planned := make([]Book, len(books))
+	for i := range books {
+		planned[i] = books[i].copyMetadata()
+		planned[i].ID = 0
+		for _, file := range books[i].BookFiles {
+			planned[i].AppendFiles(file.FilePath, file.FileType)
+		}
+	}
+	failed, err := checksumFiles(ctx, opts.Worker, planned, opts.ContinueOnError, b.track(StageChecksum, planned))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var checksums []string
+	for _, book := range planned {
+		for _, file := range book.BookFiles {
+			if file.Checksum != "" {
+				checksums = append(checksums, file.Checksum)
+			}
+		}
+	}
+	inLibrary, err := b.repo.findFilesByChecksum(checksums)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var items []importItem
+	var skipped []Duplicate
+	inBatch := make(map[string]batchTarget)
+	libraryItems := make(map[int]int) // book id to item index
+	for _, book := range planned {
+		var kept []BookFiles
+		mergeItem := -1
+		mergeBookID := 0
+		seen := make(map[string]string) // the same content twice in one book is never imported
+		for _, file := range book.BookFiles {
+			if file.Checksum == "" {
+				// failed
+				continue
+			}
+			if path, found := seen[file.Checksum]; found {
+				skipped = append(skipped, Duplicate{FilePath: file.FilePath, ExistingPath: path})
+				continue
+			}
+			seen[file.Checksum] = file.FilePath
+
+			dup := Duplicate{FilePath: file.FilePath}
+			target, foundInBatch := inBatch[file.Checksum]
+			existing, foundInLibrary := inLibrary[file.Checksum]
+			switch {
+			case foundInLibrary:
+				dup.ExistingPath = existing.filePath
+				dup.BookID = existing.bookID
+			case foundInBatch:
+				dup.ExistingPath = target.path
+			default:
+				kept = append(kept, file)
+				continue
+			}
+
+			action := policy
+			if action == DuplicateAsk {
+				action = opts.Ask(dup)
+			}
+			switch action {
+			case DuplicateNewBook:
+				kept = append(kept, file)
+			case DuplicateAddFormat:
+				if foundInLibrary {
+					mergeBookID = existing.bookID
+				} else {
+					mergeItem = target.item
+				}
+				skipped = append(skipped, dup)
+			default:
+				// DuplicateSkip
+				skipped = append(skipped, dup)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+
+		index := len(items)
+		switch {
+		case mergeBookID != 0:
+			i, ok := libraryItems[mergeBookID]
+			if !ok {
+				stored, err := b.repo.getBooks([]int{mergeBookID})
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				if len(stored) == 0 {
+					return nil, nil, nil, fmt.Errorf("book %d not found", mergeBookID)
+				}
+				i = len(items)
+				libraryItems[mergeBookID] = i
+				items = append(items, importItem{book: stored[0].copyMetadata(), bookID: mergeBookID})
+			}
+			index = i
+		case mergeItem >= 0:
+			index = mergeItem
+		default:
+			items = append(items, importItem{book: book.copyMetadata()})
+			if kept[0].FilePath != book.BookFiles[0].FilePath {
+				// the cover is in the first file, which is not imported
+				items[index].book.Cover = ""
+			}
+		}
+
+		for _, file := range kept {
+			items[index].book.appendFile(file)
+			if _, found := inBatch[file.Checksum]; !found {
+				inBatch[file.Checksum] = batchTarget{item: index, path: file.FilePath}
+			}
+		}
+	}
+
+	return items, skipped, failed, nil
+}
@@ -0,0 +1,85 @@
+package bookmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNothingToResume is returned by StartImport when the last import of a
+// source finished without failed files.
+var ErrNothingToResume = errors.New("nothing to resume")
+
+// ImportRun is the journal of an import of a source path. ImportBooks records
+// the outcome of every file in it, in the transaction of the batch that
+// imports the file, so an interrupted import can be resumed.
+type ImportRun struct {
+	ID         int
+	Source     string
+	CreateDate time.Time
+	// Resumed is set if the run continues an earlier import.
+	Resumed bool
+	// done are the files handled by earlier attempts of a resumed import.
+	done map[string]bool
+}
+
+// Done reports whether the file at path was imported, merged or skipped as
+// a duplicate by an earlier attempt of a resumed import. Failed files are
+// not done, they are retried.
+func (r *ImportRun) Done(path string) bool {
+	return r.done[path]
+}
+
+// DoneFiles returns the number of files done by earlier attempts.
+func (r *ImportRun) DoneFiles() int {
+	return len(r.done)
+}
+
+// StartImport starts the journal of an import of source, which should be an
+// absolute path. A new import removes the journal of earlier imports of
+// source. With resume, the last import of source is continued instead, an
+// error is returned if there is none, and ErrNothingToResume if it finished
+// without failed files.
+func (b *BookManager) StartImport(source string, resume bool) (*ImportRun, error) {
+	if !resume {
+		run, err := b.repo.createImportRun(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ImportRun{ID: run.id, Source: source, CreateDate: run.createDate}, nil
+	}
+
+	run, err := b.repo.lastImportRun(source)
+	if err != nil {
+		return nil, err
+	}
+	if run.id == 0 {
+		return nil, fmt.Errorf("no import of %s to resume", source)
+	}
+	outcomes, err := b.repo.journalOutcomes(run.id)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool)
+	failed := 0
+	for path, outcome := range outcomes {
+		switch outcome {
+		case OutcomeImported, OutcomeMerged, OutcomeSkippedDuplicate:
+			done[path] = true
+		case OutcomeFailed:
+			failed++
+		}
+	}
+	if run.finished && failed == 0 {
+		return nil, ErrNothingToResume
+	}
+
+	return &ImportRun{
+		ID:         run.id,
+		Source:     source,
+		CreateDate: run.createDate,
+		Resumed:    true,
+		done:       done,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package bookmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultPathTemplate is the path of book files relative to the ebm
+// directory, without extension, used when no template is configured.
+const DefaultPathTemplate = "{{.Authors}}/{{.Title}}/{{.Title}} - {{.Authors}}"
+
+// pathData is the data of path templates.
+type pathData struct {
+	Title           string
+	Authors         string // authors joined by ",", "Unknown" if none
+	FirstAuthor     string // "Unknown" if none
+	FirstAuthorSort string // first author as "Last, First"
+	Series          string
+	SeriesIndex     string // e.g. "2" or "2.5", empty if not in a series
+	Year            string // year of the publication date
+	ISBN            string
+	Publisher       string
+	Language        string
+}
+
+// authorSort returns a name as "Last, First".
+func authorSort(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) < 2 || strings.Contains(name, ",") {
+		return name
+	}
+	last := fields[len(fields)-1]
+	return last + ", " + strings.Join(fields[:len(fields)-1], " ")
+}
+
+// newPathData returns the path data of a book, values are sanitized so that
+// each one stays in one path segment.
+func newPathData(book *Book, profile SanitizeProfile) pathData {
+	first := "Unknown"
+	if len(book.Authors) > 0 {
+		first = book.Authors[0]
+	}
+	index := ""
+	if book.Series != "" {
+		index = strconv.FormatFloat(book.SeriesIndex, 'g', -1, 64)
+	}
+	year, _, _ := strings.Cut(book.PublishDate, "-")
+
+	clean := profile.replaceInvalid
+	return pathData{
+		Title:           clean(book.Title),
+		Authors:         clean(authorsName(book)),
+		FirstAuthor:     clean(first),
+		FirstAuthorSort: clean(authorSort(first)),
+		Series:          clean(book.Series),
+		SeriesIndex:     index,
+		Year:            clean(year),
+		ISBN:            clean(book.ISBN),
+		Publisher:       clean(book.Publisher),
+		Language:        clean(book.Language),
+	}
+}
+
+// parsePathTemplate parses a path template and checks it with a sample book.
+func parsePathTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultPathTemplate
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %v", err)
+	}
+
+	sample := NewBook("", "Title", []string{"First Author"}, "", nil)
+	if _, err := executePathTemplate(tmpl, SanitizePosix, &sample, ""); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// executePathTemplate returns the relative path of a book file with the
+// extension ext. Empty path segments are removed, so optional parts like the
+// series can be left out. Every segment is sanitized with profile.
+func executePathTemplate(tmpl *template.Template, profile SanitizeProfile, book *Book, ext string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newPathData(book, profile)); err != nil {
+		return "", fmt.Errorf("invalid path template: %v", err)
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(buf.String(), "/") {
+		segment = strings.TrimSpace(segment)
+		switch segment {
+		case "":
+			continue
+		case ".", "..":
+			return "", fmt.Errorf("path template: %q is not allowed in %s", segment, buf.String())
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("path template: empty path for book %q", book.Title)
+	}
+
+	last := len(segments) - 1
+	for i := range segments[:last] {
+		segments[i] = profile.name(segments[i], "", maxNameLength)
+	}
+	segments[last] = profile.fileName(segments[last], ext, 1, maxNameLength)
+
+	return filepath.Join(segments...), nil
+}
+
+// Relayout moves files of all books to the path given by the path template
+// of the book manager, and updates their paths in the db in one transaction.
+// Moved files are moved back if anything fails.
+//
+// Returns the number of files that were moved.
+func (b *BookManager) Relayout(ctx context.Context) (int, error) {
+	books, err := b.repo.FindBooks("", QueryOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	var moves []fileMove
+	targets := make(map[string]string) // new path to old path, files of the same path get a collision number
+	for i := range books {
+		book := &books[i]
+		for _, file := range book.BookFiles {
+			newPath, err := b.bookFilePath(book, FileExt(file.FilePath))
+			if err != nil {
+				return 0, err
+			}
+			newPath, err = b.sanitize.uniquePath(b.directory, newPath, func(path string) bool {
+				_, found := targets[path]
+				return found
+			})
+			if err != nil {
+				return 0, err
+			}
+			targets[newPath] = file.FilePath
+			if newPath != file.FilePath {
+				moves = append(moves, fileMove{bookID: book.ID, from: file.FilePath, to: newPath})
+			}
+		}
+	}
+	if len(moves) == 0 {
+		return 0, nil
+	}
+
+	var moved []fileMove
+	if err := b.repo.moveFiles(
+		ctx,
+		moves,
+		func() error {
+			moved, err = renameFiles(withCovers(moves))
+			return err
+		},
+		func() {
+			b.revertMoves(moved)
+		},
+	); err != nil {
+		return 0, err
+	}
+
+	for _, move := range moves {
+		b.removeEmptyDirs(filepath.Dir(move.from))
+	}
+
+	return len(moves), nil
+}
+
+// renameFiles moves files, and returns the moves done before an error.
+// Files are first moved to temporary names in their target directories, so
+// that a file can be moved to the path of another moved file, e.g. when two
+// books swap their paths.
+func renameFiles(moves []fileMove) ([]fileMove, error) {
+	sources := make(map[string]bool)
+	for _, move := range moves {
+		sources[move.from] = true
+	}
+	for _, move := range moves {
+		if _, err := os.Stat(move.to); !sources[move.to] && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("move %s: destination %s already exists", move.from, move.to)
+		}
+	}
+
+	var moved []fileMove
+	temps := make([]string, len(moves))
+	for i, move := range moves {
+		if err := os.MkdirAll(filepath.Dir(move.to), 0750); err != nil {
+			return moved, err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(move.to), ".ebmgo-move-*")
+		if err != nil {
+			return moved, fmt.Errorf("move failed: %v", err)
+		}
+		tmp.Close()
+		if err := os.Rename(move.from, tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			return moved, fmt.Errorf("move failed: %v", err)
+		}
+		temps[i] = tmp.Name()
+		moved = append(moved, fileMove{bookID: move.bookID, from: move.from, to: tmp.Name()})
+	}
+
+	for i, move := range moves {
+		if _, err := os.Stat(move.to); !os.IsNotExist(err) {
+			return moved, fmt.Errorf("move %s: destination %s already exists", move.from, move.to)
+		}
+		if err := os.Rename(temps[i], move.to); err != nil {
+			return moved, fmt.Errorf("move failed: %v", err)
+		}
+		moved = append(moved, fileMove{bookID: move.bookID, from: temps[i], to: move.to})
+	}
+
+	return moved, nil
+}
+
+// revertMoves moves files back in reverse order.
+func (b *BookManager) revertMoves(moved []fileMove) {
+	for i := len(moved) - 1; i >= 0; i-- {
+		move := moved[i]
+		os.MkdirAll(filepath.Dir(move.from), 0750)
+		os.Rename(move.to, move.from)
+		b.removeEmptyDirs(filepath.Dir(move.to))
+	}
+}
@@ -0,0 +1,145 @@
+package bookmanager
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFS holds the numbered schema migrations compiled into the binary.
+// Each file is named {version}_{description}.sql, e.g. 0002_book_details.sql.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// loadMigrations returns embedded migrations sorted by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration name %q", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %v", name, err)
+		}
+		query, err := migrationFS.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{version: version, name: name, query: string(query)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i := range migrations {
+		if migrations[i].version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence", migrations[i].name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// schemaVersion reads the schema version stored in PRAGMA user_version.
+//
+// Libraries created before versioned migrations have user_version 0 but
+// already contain the initial schema, so they are reported as version 1.
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > 0 {
+		return version, nil
+	}
+
+	var tables int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'Books'").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables > 0 {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// runMigrations upgrades the database at dbPath to the latest embedded schema.
+// A copy of an existing database is written next to it before anything is
+// changed, and every migration runs in its own transaction.
+func runMigrations(db *sql.DB, dbPath string) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	latest := len(migrations)
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if current > 0 {
+		backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, current, time.Now().Format("20060102150405"))
+		if _, err := db.Exec("VACUUM INTO $1", backupPath); err != nil {
+			return fmt.Errorf("failed to backup database: %w", err)
+		}
+		fmt.Fprintln(os.Stderr, "Database backup written to", backupPath)
+	}
+
+	for _, m := range migrations[current:] {
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Migration applied successfully:", m.name)
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.query); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+	}
+	// PRAGMA does not accept bound parameters.
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.version)); err != nil {
+		return fmt.Errorf("failed to set schema version %d: %w", m.version, err)
+	}
+
+	return tx.Commit()
+}
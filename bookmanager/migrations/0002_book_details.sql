@@ -0,0 +1,4 @@
+ALTER TABLE Books ADD COLUMN publisher TEXT NOT NULL DEFAULT '' COLLATE NOCASE;
+ALTER TABLE Books ADD COLUMN language TEXT NOT NULL DEFAULT '' COLLATE NOCASE;
+ALTER TABLE Books ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE Books ADD COLUMN publishDate TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS Series(
+    series TEXT NOT NULL PRIMARY KEY
+);
+
+ALTER TABLE Books ADD COLUMN series TEXT DEFAULT NULL REFERENCES Series(series) ON DELETE SET NULL;
+ALTER TABLE Books ADD COLUMN seriesIndex REAL NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS BooksSeries ON Books(series, seriesIndex);
+
+-- Rebuild full text search to make series searchable with 'series:'
+DROP TRIGGER IF EXISTS InsertBookFts;
+DROP TRIGGER IF EXISTS UpdateBookFts;
+DROP TRIGGER IF EXISTS DeleteBookFts;
+DROP TABLE IF EXISTS BooksFts;
+
+CREATE VIRTUAL TABLE BooksFts USING fts5(
+   bookId, title, isbn, series, createDate, modifiedDate
+);
+
+INSERT INTO BooksFts(bookId, title, isbn, series, createDate, modifiedDate)
+    SELECT bookId, title, isbn, series, createDate, modifiedDate FROM Books;
+
+CREATE TRIGGER InsertBookFts
+    AFTER INSERT ON Books
+BEGIN
+    INSERT INTO BooksFts(bookId, title, isbn, series, createDate, modifiedDate)
+VALUES (NEW.bookId, NEW.title, NEW.isbn, NEW.series, NEW.createDate, NEW.modifiedDate);
+END;
+
+CREATE TRIGGER UpdateBookFts
+    AFTER UPDATE ON Books
+BEGIN
+    UPDATE BooksFts
+    SET
+        title = NEW.title,
+        isbn = NEW.isbn,
+        series = NEW.series,
+        modifiedDate = NEW.modifiedDate
+    WHERE
+        bookId = NEW.bookId;
+END;
+
+CREATE TRIGGER DeleteBookFts
+    AFTER DELETE ON Books
+BEGIN
+    DELETE FROM BooksFts
+    WHERE bookId = OLD.bookId;
+END;
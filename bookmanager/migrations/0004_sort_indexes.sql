@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS BooksTitle ON Books(title);
+CREATE INDEX IF NOT EXISTS BooksCreateDate ON Books(createDate);
+CREATE INDEX IF NOT EXISTS BooksModifiedDate ON Books(modifiedDate);
+CREATE INDEX IF NOT EXISTS BookAuthorsAuthor ON BookAuthors(author, bookId);
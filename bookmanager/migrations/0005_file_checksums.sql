@@ -0,0 +1,3 @@
+ALTER TABLE BookFiles ADD COLUMN checksum TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS BookFilesChecksum ON BookFiles(checksum);
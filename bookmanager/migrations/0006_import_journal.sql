@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ImportRuns(
+    runId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    createDate TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finishDate TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ImportRunsSource ON ImportRuns(source);
+
+-- Outcome of every file of an import run, written in the transaction that
+-- imports the file so that an interrupted import can be resumed
+CREATE TABLE IF NOT EXISTS ImportJournal(
+    runId INTEGER NOT NULL REFERENCES ImportRuns(runId) ON DELETE CASCADE,
+    filePath TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    bookId INTEGER DEFAULT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (runId, filePath)
+);
@@ -0,0 +1 @@
+ALTER TABLE Books ADD COLUMN asin TEXT NOT NULL DEFAULT '' COLLATE NOCASE;
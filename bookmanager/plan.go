@@ -0,0 +1,87 @@
+package bookmanager
+
+import (
+	"context"
+	"os"
+)
+
+// ImportPlan is what ImportBooks would do with books, see PlanImport.
+type ImportPlan struct {
+	Books []PlannedBook
+	// Duplicates are files that are not imported because their content is
+	// already in the library or earlier in the import.
+	Duplicates []Duplicate
+	// Failed are files that can not be read, with ImportOptions.ContinueOnError.
+	Failed []FileResult
+	// TotalBytes is the size of all files to import.
+	TotalBytes int64
+}
+
+// PlannedBook is a book of an import plan.
+type PlannedBook struct {
+	Title   string
+	Authors []string
+	// BookID is the book in the library the files are added to as new
+	// formats, 0 for a new book.
+	BookID int
+	Files  []PlannedFile
+}
+
+// PlannedFile is a file of an import plan.
+type PlannedFile struct {
+	Source      string
+	Destination string
+	FileType    string
+	Size        int64
+}
+
+// PlanImport resolves duplicates and destination paths of books like
+// ImportBooks, without changing the db or the ebm directory.
+//
+// Destinations are computed independently of concurrent changes, a file
+// created in the library before the import may still get another
+// collision number.
+func (b *BookManager) PlanImport(ctx context.Context, books []Book, opts ImportOptions) (ImportPlan, error) {
+	items, duplicates, failed, err := b.planImport(ctx, books, opts)
+	if err != nil {
+		return ImportPlan{}, err
+	}
+
+	plan := ImportPlan{Duplicates: duplicates, Failed: failed}
+	planned := make(map[string]bool) // destinations of the plan
+	for _, item := range items {
+		book := PlannedBook{
+			Title:   item.book.Title,
+			Authors: item.book.Authors,
+			BookID:  item.bookID,
+		}
+		for _, file := range item.book.BookFiles {
+			info, err := os.Stat(file.FilePath)
+			if err != nil {
+				return ImportPlan{}, err
+			}
+			dst, err := b.bookFilePath(&item.book, FileExt(file.FilePath))
+			if err != nil {
+				return ImportPlan{}, err
+			}
+			dst, err = b.sanitize.uniquePath(b.directory, dst, func(path string) bool {
+				return planned[path] || fileExists(path)
+			})
+			if err != nil {
+				return ImportPlan{}, err
+			}
+			planned[dst] = true
+
+			book.Files = append(book.Files, PlannedFile{
+				Source:      file.FilePath,
+				Destination: dst,
+				FileType:    file.FileType,
+				Size:        info.Size(),
+			})
+			plan.TotalBytes += info.Size()
+		}
+		plan.Books = append(plan.Books, book)
+	}
+
+	return plan, nil
+}
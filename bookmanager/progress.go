@@ -0,0 +1,124 @@
+package bookmanager
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// ProgressStage is a stage of a long running operation.
+type ProgressStage string
+
+const (
+	// StageChecksum computes checksums of the files to import.
+	StageChecksum ProgressStage = "checksum"
+	// StageTransfer copies, moves or links files into the ebm directory.
+	StageTransfer ProgressStage = "transfer"
+	// StageExport copies files out of the ebm directory.
+	StageExport ProgressStage = "export"
+)
+
+// Progress is a progress event, sent after every file of a stage and once
+// when the stage starts.
+type Progress struct {
+	Stage      ProgressStage
+	Files      int // files done
+	TotalFiles int
+	Bytes      int64 // bytes done
+	TotalBytes int64
+	Path       string // file just done, empty for the start event
+	Elapsed    time.Duration
+}
+
+// ETA returns the estimated time until the stage is done, from the bytes done
+// so far, or the files if the size is unknown. It is 0 when nothing is done yet.
+func (p Progress) ETA() time.Duration {
+	done, total := float64(p.Bytes), float64(p.TotalBytes)
+	if p.TotalBytes == 0 {
+		done, total = float64(p.Files), float64(p.TotalFiles)
+	}
+	if done == 0 || done >= total {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * (total - done) / done)
+}
+
+// ProgressFunc receives progress events. Calls are not concurrent.
+type ProgressFunc func(Progress)
+
+// OnProgress sets the function receiving progress events of ImportBooks,
+// PlanImport and Export, nil to receive none.
+func (b *BookManager) OnProgress(fn ProgressFunc) {
+	b.progress = fn
+}
+
+// progressTracker counts files of a stage and sends progress events.
+// A nil tracker does nothing.
+type progressTracker struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	start time.Time
+	p     Progress
+}
+
+// newProgressTracker returns a tracker of a stage, nil if fn is nil.
+// The start event is sent.
+func newProgressTracker(fn ProgressFunc, stage ProgressStage, files int, bytes int64) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	t := &progressTracker{
+		fn:    fn,
+		start: time.Now(),
+		p:     Progress{Stage: stage, TotalFiles: files, TotalBytes: bytes},
+	}
+	fn(t.p)
+
+	return t
+}
+
+// add counts a file of size bytes as done.
+func (t *progressTracker) add(path string, size int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.p.Files++
+	t.p.Bytes += size
+	t.p.Path = path
+	t.p.Elapsed = time.Since(t.start)
+	t.fn(t.p)
+}
+
+// track returns a tracker of a stage over the files of books, nil if
+// nobody receives progress events.
+func (b *BookManager) track(stage ProgressStage, books []Book) *progressTracker {
+	if b.progress == nil {
+		return nil
+	}
+	files, size := booksSize(books)
+	return newProgressTracker(b.progress, stage, files, size)
+}
+
+// fileSize returns the size of the file at path, 0 if it can not be read.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// booksSize returns the number and the total size of files of books.
+func booksSize(books []Book) (int, int64) {
+	files, size := 0, int64(0)
+	for _, book := range books {
+		for _, file := range book.BookFiles {
+			files++
+			size += fileSize(file.FilePath)
+		}
+	}
+	return files, size
+}
@@ -0,0 +1,375 @@
+package bookmanager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Search queries filter books by their metadata, e.g.
+//
+//	author:tolkien tag:fantasy format:epub -tag:unread title:"ring*"
+//
+// Terms are matched case-insensitively as substrings. A value containing '*'
+// is matched as a whole, where '*' matches any characters. Terms without a
+// field match the start of words of title, series and isbn through the full
+// text index, and authors and tags as substrings. Terms are combined with
+// AND unless OR is given, NOT or '-' negates a term and parentheses group them.
+
+// QueryError is returned for an invalid search query.
+type QueryError struct {
+	Pos int // byte offset in the query
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// queryFields maps search fields to SQL conditions on a book aliased as b.
+// %s is replaced by the parameter placeholder.
+var queryFields = map[string]string{
+	"title":     `b.title LIKE %s ESCAPE '\'`,
+	"isbn":      `b.isbn LIKE %s ESCAPE '\'`,
+	"asin":      `b.asin LIKE %s ESCAPE '\'`,
+	"publisher": `b.publisher LIKE %s ESCAPE '\'`,
+	"language":  `b.language LIKE %s ESCAPE '\'`,
+	"series":    `COALESCE(b.series, '') LIKE %s ESCAPE '\'`,
+	"author":    `EXISTS (SELECT 1 FROM BookAuthors q WHERE q.bookId = b.bookId AND q.author LIKE %s ESCAPE '\')`,
+	"tag":       `EXISTS (SELECT 1 FROM BookTags q WHERE q.bookId = b.bookId AND q.tag LIKE %s ESCAPE '\')`,
+	"format":    `EXISTS (SELECT 1 FROM BookFiles q WHERE q.bookId = b.bookId AND q.fileType LIKE %s ESCAPE '\')`,
+}
+
+// anyFields are matched by terms without a field.
+var anyFields = []string{"title", "author", "tag", "series", "isbn"}
+
+// ftsFields are the fields of anyFields indexed by BooksFts.
+var ftsFields = map[string]bool{"title": true, "isbn": true, "series": true}
+
+// ftsCondition matches books whose BooksFts row matches %s.
+const ftsCondition = `b.bookId IN (SELECT CAST(bookId AS INTEGER) FROM BooksFts WHERE BooksFts MATCH %s)`
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenField
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexQuery splits a query into tokens.
+func lexQuery(query string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, pos: i})
+			i++
+		case c == '-' && (len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenField):
+			tokens = append(tokens, token{kind: tokenNot, pos: i})
+			i++
+		case c == '"':
+			value, next, err := lexString(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: value, pos: i})
+			i = next
+		default:
+			start := i
+			for i < len(query) && !strings.ContainsRune(" \t\r\n()\"", rune(query[i])) {
+				if query[i] == ':' && (len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenField) {
+					break
+				}
+				i++
+			}
+			word := query[start:i]
+
+			if i < len(query) && query[i] == ':' {
+				field := strings.ToLower(word)
+				if _, ok := queryFields[field]; !ok {
+					return nil, &QueryError{Pos: start, Msg: fmt.Sprintf("unknown field %q", word)}
+				}
+				tokens = append(tokens, token{kind: tokenField, value: field, pos: start})
+				i++
+				continue
+			}
+
+			switch word {
+			case "AND", "and":
+				tokens = append(tokens, token{kind: tokenAnd, pos: start})
+			case "OR", "or":
+				tokens = append(tokens, token{kind: tokenOr, pos: start})
+			case "NOT", "not":
+				tokens = append(tokens, token{kind: tokenNot, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, value: word, pos: start})
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(query)})
+
+	return tokens, nil
+}
+
+// lexString reads a double quoted string starting at i, returns its value and
+// the position after the closing quote.
+func lexString(query string, i int) (string, int, error) {
+	start := i
+	var value strings.Builder
+	for i++; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if i+1 < len(query) {
+				i++
+			}
+			value.WriteByte(query[i])
+		case '"':
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(query[i])
+		}
+	}
+
+	return "", 0, &QueryError{Pos: start, Msg: "unterminated quoted string"}
+}
+
+// queryNode is a node of a parsed search query.
+type queryNode interface {
+	// sql returns the SQL condition of the node and appends its parameters to args.
+	sql(args *[]interface{}) string
+}
+
+type termNode struct {
+	field string // empty for any field
+	value string
+}
+
+type notNode struct {
+	node queryNode
+}
+
+type binaryNode struct {
+	op    string // AND, OR
+	left  queryNode
+	right queryNode
+}
+
+// likePattern converts a search value to a LIKE pattern.
+func likePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	value = replacer.Replace(value)
+	if strings.Contains(value, "*") {
+		return strings.ReplaceAll(value, "*", "%")
+	}
+	return "%" + value + "%"
+}
+
+// ftsMatch converts a search value to a BooksFts query matching words of
+// ftsFields that start with the value. It returns false for a value the index
+// can not match, one with a '*' before its end or without letters or digits.
+func ftsMatch(value string) (string, bool) {
+	value = strings.TrimRight(value, "*")
+	if strings.Contains(value, "*") || strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) < 0 {
+		return "", false
+	}
+	return `{title isbn series} : "` + strings.ReplaceAll(value, `"`, `""`) + `"*`, true
+}
+
+func (n termNode) sql(args *[]interface{}) string {
+	fields := []string{n.field}
+	if n.field == "" {
+		fields = anyFields
+	}
+
+	conditions := make([]string, 0, len(fields))
+	match, fts := "", false
+	if n.field == "" {
+		match, fts = ftsMatch(n.value)
+	}
+	if fts {
+		*args = append(*args, match)
+		conditions = append(conditions, fmt.Sprintf(ftsCondition, "$"+strconv.Itoa(len(*args))))
+	}
+	for _, field := range fields {
+		if fts && ftsFields[field] {
+			continue
+		}
+		*args = append(*args, likePattern(n.value))
+		conditions = append(conditions, fmt.Sprintf(queryFields[field], "$"+strconv.Itoa(len(*args))))
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+func (n notNode) sql(args *[]interface{}) string {
+	return "NOT " + n.node.sql(args)
+}
+
+func (n binaryNode) sql(args *[]interface{}) string {
+	return "(" + n.left.sql(args) + " " + n.op + " " + n.right.sql(args) + ")"
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr parses: and { OR and }
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: unary { [AND] unary }
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenField, tokenNot, tokenLParen:
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "AND", left: left, right: right}
+	}
+}
+
+// parseUnary parses: NOT unary | ( or ) | [field:] value
+func (p *queryParser) parseUnary() (queryNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNot:
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node: node}, nil
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &QueryError{Pos: closing.pos, Msg: "missing closing parenthesis"}
+		}
+		return node, nil
+	case tokenField:
+		value := p.next()
+		if value.kind != tokenWord || value.value == "" {
+			return nil, &QueryError{Pos: value.pos, Msg: fmt.Sprintf("missing value for field %q", t.value)}
+		}
+		return termNode{field: t.value, value: value.value}, nil
+	case tokenWord:
+		return termNode{value: t.value}, nil
+	case tokenEOF:
+		return nil, &QueryError{Pos: t.pos, Msg: "unexpected end of query"}
+	default:
+		return nil, &QueryError{Pos: t.pos, Msg: "unexpected " + t.describe()}
+	}
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenRParen:
+		return "')'"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
+// parseQuery parses a search query. It returns nil for an empty query.
+//
+// The grammar, from the lowest precedence:
+//
+//	query = or
+//	or    = and { "OR" and }
+//	and   = unary { [ "AND" ] unary }
+//	unary = ( "NOT" | "-" ) unary | "(" or ")" | [ field ":" ] value
+//	value = word | '"' { char | '\' char } '"'
+//
+// AND, OR and NOT are also accepted in lower case. A word ends at white
+// space, a parenthesis or a quote. A word followed by ':' is a field, one of
+// queryFields, and the value after it can not be empty. A '-' negates only
+// at the start of a term, "-tag:read" but "title:-x" and "sci-fi" are values.
+// A term without a field matches any of anyFields, ftsFields through the
+// full text index when ftsMatch can convert the value. See likePattern for
+// how values are matched otherwise.
+func parseQuery(query string) (queryNode, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &QueryError{Pos: t.pos, Msg: "unexpected " + t.describe()}
+	}
+
+	return node, nil
+}
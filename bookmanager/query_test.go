@@ -0,0 +1,175 @@
+package bookmanager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func termOf(field, value string) termNode {
+	return termNode{field: field, value: value}
+}
+
+func andNode(left, right queryNode) binaryNode {
+	return binaryNode{op: "AND", left: left, right: right}
+}
+
+func orNode(left, right queryNode) binaryNode {
+	return binaryNode{op: "OR", left: left, right: right}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  queryNode
+	}{
+		{name: "empty", query: " \t", want: nil},
+		{name: "word", query: "dune", want: termOf("", "dune")},
+		{name: "implicit and", query: "dune herbert", want: andNode(termOf("", "dune"), termOf("", "herbert"))},
+		{name: "explicit and", query: "dune AND herbert", want: andNode(termOf("", "dune"), termOf("", "herbert"))},
+		{name: "lower case keywords", query: "a and not b or c", want: orNode(andNode(termOf("", "a"), notNode{termOf("", "b")}), termOf("", "c"))},
+		{name: "and binds tighter than or", query: "a OR b c", want: orNode(termOf("", "a"), andNode(termOf("", "b"), termOf("", "c")))},
+		{name: "or is left associative", query: "a OR b OR c", want: orNode(orNode(termOf("", "a"), termOf("", "b")), termOf("", "c"))},
+		{name: "not binds tighter than and", query: "NOT a b", want: andNode(notNode{termOf("", "a")}, termOf("", "b"))},
+		{name: "double not", query: "NOT -a", want: notNode{notNode{termOf("", "a")}}},
+		{name: "minus", query: "-tag:read", want: notNode{termOf("tag", "read")}},
+		{name: "minus inside a word", query: "sci-fi", want: termOf("", "sci-fi")},
+		{name: "minus after field", query: "title:-x", want: termOf("title", "-x")},
+		{name: "parentheses", query: "(a OR b) c", want: andNode(orNode(termOf("", "a"), termOf("", "b")), termOf("", "c"))},
+		{name: "nested parentheses", query: "-((a))", want: notNode{termOf("", "a")}},
+		{name: "parentheses end a word", query: "(a)b", want: andNode(termOf("", "a"), termOf("", "b"))},
+		{name: "quoted", query: `title:"the ring"`, want: termOf("title", "the ring")},
+		{name: "quoted keyword", query: `"OR"`, want: termOf("", "OR")},
+		{name: "quoted escapes", query: `"a \"b\" \\c"`, want: termOf("", `a "b" \c`)},
+		{name: "quote ends a word", query: `a"b"`, want: andNode(termOf("", "a"), termOf("", "b"))},
+		{name: "star", query: `title:"ring*"`, want: termOf("title", "ring*")},
+		{name: "field is case insensitive", query: "Author:Tolkien", want: termOf("author", "Tolkien")},
+		{name: "colon in value", query: "title:a:b", want: termOf("title", "a:b")},
+		{name: "title", query: "title:x", want: termOf("title", "x")},
+		{name: "isbn", query: "isbn:x", want: termOf("isbn", "x")},
+		{name: "asin", query: "asin:x", want: termOf("asin", "x")},
+		{name: "publisher", query: "publisher:x", want: termOf("publisher", "x")},
+		{name: "language", query: "language:x", want: termOf("language", "x")},
+		{name: "series", query: "series:x", want: termOf("series", "x")},
+		{name: "author", query: "author:x", want: termOf("author", "x")},
+		{name: "tag", query: "tag:x", want: termOf("tag", "x")},
+		{name: "format", query: "format:x", want: termOf("format", "x")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseQuery(%q) error = %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQuery(%q) = %#v, want %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryError(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		pos   int
+	}{
+		{name: "missing closing parenthesis", query: "(a OR b", pos: 7},
+		{name: "unexpected closing parenthesis", query: "a)", pos: 1},
+		{name: "empty parentheses", query: "a ()", pos: 3},
+		{name: "unterminated quote", query: `title:"ring`, pos: 6},
+		{name: "unknown field", query: "a foo:bar", pos: 2},
+		{name: "missing field value", query: "title:", pos: 6},
+		{name: "empty field value", query: `title:""`, pos: 6},
+		{name: "missing operand of and", query: "a AND", pos: 5},
+		{name: "missing operand of or", query: "a OR OR b", pos: 5},
+		{name: "missing operand of not", query: "a -", pos: 3},
+		{name: "leading or", query: "OR a", pos: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseQuery(tt.query)
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("parseQuery(%q) error = %v, want *QueryError", tt.query, err)
+			}
+			if queryErr.Pos != tt.pos {
+				t.Errorf("parseQuery(%q) error position = %d, want %d (%v)", tt.query, queryErr.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestLexQuery(t *testing.T) {
+	got, err := lexQuery(`-tag:"sci fi" (a OR b)`)
+	if err != nil {
+		t.Fatalf("lexQuery() error = %v", err)
+	}
+	want := []token{
+		{kind: tokenNot, pos: 0},
+		{kind: tokenField, value: "tag", pos: 1},
+		{kind: tokenWord, value: "sci fi", pos: 5},
+		{kind: tokenLParen, pos: 14},
+		{kind: tokenWord, value: "a", pos: 15},
+		{kind: tokenOr, pos: 17},
+		{kind: tokenWord, value: "b", pos: 20},
+		{kind: tokenRParen, pos: 21},
+		{kind: tokenEOF, pos: 22},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lexQuery() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "ring", want: "%ring%"},
+		{value: "ring*", want: "ring%"},
+		{value: "*of*ring", want: "%of%ring"},
+		{value: "100%", want: `%100\%%`},
+		{value: "a_b", want: `%a\_b%`},
+		{value: `a\b`, want: `%a\\b%`},
+		{value: `50%_*`, want: `50\%\_%`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := likePattern(tt.value); got != tt.want {
+				t.Errorf("likePattern(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFtsMatch(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{value: "ring", want: `{title isbn series} : "ring"*`, ok: true},
+		{value: "ring**", want: `{title isbn series} : "ring"*`, ok: true},
+		{value: "the ring", want: `{title isbn series} : "the ring"*`, ok: true},
+		{value: `say "hi"`, want: `{title isbn series} : "say ""hi"""*`, ok: true},
+		{value: "Война", want: `{title isbn series} : "Война"*`, ok: true},
+		{value: "of*ring"},
+		{value: "*ring"},
+		{value: "%"},
+		{value: "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ftsMatch(tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ftsMatch(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
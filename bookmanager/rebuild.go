@@ -0,0 +1,77 @@
+package bookmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// rebuildBatchSize is the number of books inserted per transaction, it keeps
+// the number of query parameters below the SQLite limit.
+const rebuildBatchSize = 500
+
+// RebuildDB writes a new db next to the db of the ebm directory from books
+// whose files are already stored in it, and returns the path of the new db.
+// The existing db is not opened, so it may be missing or corrupted.
+//
+// The added and modified dates of a book are the oldest and newest
+// modification time of its files. If anything fails the new db is removed.
+func RebuildDB(ctx context.Context, ebmDir string, books []Book, worker int) (dbPath string, err error) {
+	dbPath = filepath.Join(ebmDir, fmt.Sprintf("ebm.db.rebuilt-%s", time.Now().Format("20060102150405")))
+	if _, err := os.Stat(dbPath); err == nil {
+		return "", fmt.Errorf("%s already exists", dbPath)
+	}
+
+	db, err := openSqlite(dbPath)
+	if err != nil {
+		os.Remove(dbPath)
+		return "", fmt.Errorf("failed create db: %v", err)
+	}
+	defer func() {
+		db.Close()
+		if err != nil {
+			os.Remove(dbPath)
+		}
+	}()
+	repo := newRepository(db)
+
+	if _, err := checksumFiles(ctx, worker, books, false, nil); err != nil {
+		return "", err
+	}
+
+	pending := make([]*Book, 0, len(books))
+	for i := range books {
+		book := &books[i]
+		book.ID = 0
+		for _, file := range book.BookFiles {
+			info, err := os.Stat(file.FilePath)
+			if err != nil {
+				return "", err
+			}
+			modTime := info.ModTime()
+			if book.CreateDate.IsZero() || modTime.Before(book.CreateDate) {
+				book.CreateDate = modTime
+			}
+			if modTime.After(book.ModifiedDate) {
+				book.ModifiedDate = modTime
+			}
+		}
+		pending = append(pending, book)
+	}
+
+	for start := 0; start < len(pending); start += rebuildBatchSize {
+		batch := pending[start:min(start+rebuildBatchSize, len(pending))]
+		err := repo.CreateBooks(
+			ctx,
+			func() ([]*Book, error) { return batch, nil },
+			func() {},
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return dbPath, nil
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package bookmanager
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request, _IOW(0x94, 9, int).
+const ficlone = 0x40049409
+
+// reflink clones src to dst with the FICLONE ioctl.
+func reflink(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, destFile.Fd(), ficlone, srcFile.Fd())
+	destFile.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		if errno == syscall.EOPNOTSUPP || errno == syscall.EINVAL || errno == syscall.ENOTTY || errno == syscall.EXDEV {
+			return fmt.Errorf("reflink %s: %w", dst, syscall.ENOTSUP)
+		}
+		return fmt.Errorf("reflink %s: %v", dst, errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bookmanager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// reflink is only implemented on Linux.
+func reflink(src, dst string) error {
+	return fmt.Errorf("reflink %s: %w", dst, errors.ErrUnsupported)
+}
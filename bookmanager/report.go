@@ -0,0 +1,73 @@
+package bookmanager
+
+// Outcome is what an import did with a file.
+type Outcome string
+
+const (
+	// OutcomeImported is a file imported as a format of a new book.
+	OutcomeImported Outcome = "imported"
+	// OutcomeMerged is a file added as a format of a book already in the library.
+	OutcomeMerged Outcome = "merged"
+	// OutcomeSkippedUnsupported is a file whose type is not supported.
+	OutcomeSkippedUnsupported Outcome = "skipped-unsupported"
+	// OutcomeSkippedDuplicate is a file whose content is already in the
+	// library or earlier in the import.
+	OutcomeSkippedDuplicate Outcome = "skipped-duplicate"
+	// OutcomeFailed is a file that could not be imported.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Outcomes are all outcomes in report order.
+var Outcomes = []Outcome{
+	OutcomeImported,
+	OutcomeMerged,
+	OutcomeSkippedUnsupported,
+	OutcomeSkippedDuplicate,
+	OutcomeFailed,
+}
+
+// FileResult is the outcome of a file of an import.
+type FileResult struct {
+	Path    string
+	Outcome Outcome
+	// BookID is the book the file was imported to, or the book of the
+	// existing file of a duplicate.
+	BookID int
+	// Destination is the path of the imported file in the library.
+	Destination string
+	// ExistingPath is the file with the same content as a duplicate.
+	ExistingPath string
+	// Reason is why the file failed.
+	Reason string
+}
+
+// ImportReport is the outcome of every file of an import.
+type ImportReport struct {
+	Files []FileResult
+}
+
+// Count returns the number of files with the given outcome.
+func (r ImportReport) Count(outcome Outcome) int {
+	n := 0
+	for _, file := range r.Files {
+		if file.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// duplicateResult returns the result of a skipped duplicate.
+func duplicateResult(dup Duplicate) FileResult {
+	return FileResult{
+		Path:         dup.FilePath,
+		Outcome:      OutcomeSkippedDuplicate,
+		BookID:       dup.BookID,
+		ExistingPath: dup.ExistingPath,
+	}
+}
+
+// failedResult returns the result of a file that failed with err.
+func failedResult(path string, err error) FileResult {
+	return FileResult{Path: path, Outcome: OutcomeFailed, Reason: err.Error()}
+}
@@ -22,7 +22,20 @@ func (repo *repository) CreateBooks(
 	ctx context.Context,
 	fn func() ([]*Book, error),
 	rollbackFn func(),
-) error {
+) (err error) {
+	return repo.createBooks(ctx, 0, fn, nil, rollbackFn)
+}
+
+// createBooks inserts the books returned by fn in one transaction. If runID
+// is set, the results returned by journal are written to the import journal
+// of the run in the same transaction, after the books got their ID.
+func (repo *repository) createBooks(
+	ctx context.Context,
+	runID int,
+	fn func() ([]*Book, error),
+	journal func() []FileResult,
+	rollbackFn func(),
+) (err error) {
 	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
 		return err
@@ -30,11 +43,15 @@ func (repo *repository) CreateBooks(
 
 	var books []*Book
 	defer func() {
-		if err != nil || ctx.Err() != nil {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
 			tx.Rollback()
 			rollbackFn()
-		} else {
-			tx.Commit()
 		}
 	}()
 
@@ -42,37 +59,68 @@ func (repo *repository) CreateBooks(
 	if err != nil {
 		return err
 	}
-	if len(books) <= 0 {
-		return nil
+	if len(books) > 0 {
+		if err = repo.batchInsertBooks(ctx, tx, books); err != nil {
+			return err
+		}
+	}
+	if runID != 0 {
+		err = writeJournal(ctx, tx, runID, journal())
 	}
 
-	return repo.batchInsertBooks(ctx, tx, books)
+	return err
 }
 
+// batchInsertBooks inserts books with their files, authors and tags.
+// Books with an ID are already stored, only their files are inserted.
 func (repo *repository) batchInsertBooks(ctx context.Context, tx *sql.Tx, books []*Book) error {
 	now := time.Now()
 
+	var newBooks []*Book
+	for _, book := range books {
+		if book.ID == 0 {
+			newBooks = append(newBooks, book)
+		}
+	}
+
 	valueStrings := make([]string, 0)
 	valueArgs := make([]interface{}, 0)
 	param := 1
-	for _, book := range books {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", param, param+1, param+2, param+3, param+4))
+	for _, book := range newBooks {
+		valueStrings = append(valueStrings, fmt.Sprintf(
+			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			param, param+1, param+2, param+3, param+4, param+5, param+6, param+7, param+8, param+9, param+10, param+11,
+		))
 		valueArgs = append(valueArgs, nil)
 		valueArgs = append(valueArgs, book.Title)
 		valueArgs = append(valueArgs, book.ISBN)
-		valueArgs = append(valueArgs, now)
-		valueArgs = append(valueArgs, now)
-		param += 5
+		valueArgs = append(valueArgs, book.ASIN)
+		valueArgs = append(valueArgs, book.Publisher)
+		valueArgs = append(valueArgs, book.Language)
+		valueArgs = append(valueArgs, book.Description)
+		valueArgs = append(valueArgs, book.PublishDate)
+		valueArgs = append(valueArgs, nullString(book.Series))
+		valueArgs = append(valueArgs, book.SeriesIndex)
+		valueArgs = append(valueArgs, timeOr(book.CreateDate, now))
+		valueArgs = append(valueArgs, timeOr(book.ModifiedDate, now))
+		param += 12
 	}
 
 	if param <= 1 {
-		return nil
+		return repo.batchInsertFiles(ctx, tx, books)
 	}
 
 	query := fmt.Sprintf(`
-        INSERT INTO Books (bookId, title, isbn, createDate, modifiedDate) VALUES %s
+        INSERT INTO Books (
+            bookId, title, isbn, asin, publisher, language, description, publishDate,
+            series, seriesIndex, createDate, modifiedDate
+        ) VALUES %s
 	`, strings.Join(valueStrings, ","))
 
+	if err := repo.batchInsertSeries(ctx, tx, newBooks); err != nil {
+		return err
+	}
+
 	res, err := tx.ExecContext(ctx, query, valueArgs...)
 	if err != nil {
 		return err
@@ -85,26 +133,67 @@ func (repo *repository) batchInsertBooks(ctx context.Context, tx *sql.Tx, books
 
 	// Assign IDs to booksDB
 	// Setup batch insert bookFiles
-	startID := int(lastID) - len(books) + 1
-	for i := range books {
-		books[i].ID = startID + i
+	startID := int(lastID) - len(newBooks) + 1
+	for i := range newBooks {
+		newBooks[i].ID = startID + i
 	}
 
 	if err := repo.batchInsertFiles(ctx, tx, books); err != nil {
-		return nil
+		return err
 	}
 
-	if err := repo.batchInsertAuthors(ctx, tx, books); err != nil {
-		return nil
+	if err := repo.batchInsertAuthors(ctx, tx, newBooks); err != nil {
+		return err
 	}
 
-	if err := repo.batchInsertTags(ctx, tx, books); err != nil {
-		return nil
+	if err := repo.batchInsertTags(ctx, tx, newBooks); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// timeOr returns t, or def if t is zero.
+func timeOr(t time.Time, def time.Time) time.Time {
+	if t.IsZero() {
+		return def
+	}
+	return t
+}
+
+func (repo *repository) batchInsertSeries(ctx context.Context, tx *sql.Tx, books []*Book) error {
+	valuesString := make([]string, 0)
+	valueArgs := make([]interface{}, 0)
+	param := 1
+	for i := range books {
+		if books[i].Series == "" {
+			continue
+		}
+		valuesString = append(valuesString, fmt.Sprintf("($%d)", param))
+		valueArgs = append(valueArgs, books[i].Series)
+		param++
+	}
+
+	if param <= 1 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+        INSERT INTO Series (series) VALUES %s ON CONFLICT(series) DO NOTHING
+        `, strings.Join(valuesString, ","))
+	_, err := tx.ExecContext(ctx, query, valueArgs...)
+
+	return err
+}
+
+// nullString returns nil for empty string to store NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func (repo *repository) batchInsertFiles(ctx context.Context, tx *sql.Tx, books []*Book) error {
 	now := time.Now()
 
@@ -113,19 +202,24 @@ func (repo *repository) batchInsertFiles(ctx context.Context, tx *sql.Tx, books
 	param := 1
 	for i := range books {
 		for _, file := range books[i].BookFiles {
-			valuesString = append(valuesString, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", param, param+1, param+2, param+3, param+4))
+			valuesString = append(valuesString, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", param, param+1, param+2, param+3, param+4, param+5))
 			valueArgs = append(valueArgs, books[i].ID)
 			valueArgs = append(valueArgs, file.FilePath)
 			valueArgs = append(valueArgs, file.FileType)
+			valueArgs = append(valueArgs, file.Checksum)
 			valueArgs = append(valueArgs, now)
 			valueArgs = append(valueArgs, now)
-			param += 5
+			param += 6
 		}
 	}
 
+	if param <= 1 {
+		return nil
+	}
+
 	query := fmt.Sprintf(`
         INSERT INTO
-            BookFiles (bookId, filePath, fileType, createDate, modifiedDate)
+            BookFiles (bookId, filePath, fileType, checksum, createDate, modifiedDate)
         VALUES %s
         `, strings.Join(valuesString, ","))
 	_, err := tx.ExecContext(ctx, query, valueArgs...)
@@ -230,13 +324,41 @@ func (repo *repository) batchInsertTags(ctx context.Context, tx *sql.Tx, books [
 }
 
 type bookDB struct {
-	ID       int
-	Title    string
-	ISBN     string
-	Author   string
-	Tag      *string
-	FilePath string
-	FileType string
+	ID           int
+	Title        string
+	ISBN         string
+	ASIN         string
+	Publisher    string
+	Language     string
+	Description  string
+	PublishDate  string
+	Series       *string
+	SeriesIndex  float64
+	CreateDate   time.Time
+	ModifiedDate time.Time
+	Author       *string
+	Tag          *string
+	FilePath     *string // nil for a book without files
+	FileType     *string
+	Checksum     *string
+}
+
+// newBookFromDB returns a book with the metadata of a row without authors, tags and files.
+func newBookFromDB(b bookDB) Book {
+	book := NewBook(b.ISBN, b.Title, []string{}, b.Publisher, []string{})
+	book.ID = b.ID
+	book.ASIN = b.ASIN
+	book.Language = b.Language
+	book.Description = b.Description
+	book.PublishDate = b.PublishDate
+	if b.Series != nil {
+		book.Series = *b.Series
+	}
+	book.SeriesIndex = b.SeriesIndex
+	book.CreateDate = b.CreateDate
+	book.ModifiedDate = b.ModifiedDate
+
+	return book
 }
 
 func parseBooks(bookDBs []bookDB) []Book {
@@ -245,58 +367,145 @@ func parseBooks(bookDBs []bookDB) []Book {
 	currentBook := Book{}
 	for _, b := range bookDBs {
 		if currentID == 0 {
-			currentBook = NewBook(b.ISBN, b.Title, []string{}, "", []string{})
-			currentBook.ID = b.ID
+			currentBook = newBookFromDB(b)
 			currentID = b.ID
 		} else if b.ID != currentID {
 			books = append(books, currentBook)
-			currentBook = NewBook(b.ISBN, b.Title, []string{}, "", []string{})
-			currentBook.ID = b.ID
+			currentBook = newBookFromDB(b)
 			currentID = b.ID
 		}
 
-		currentBook.AppendAuthors(b.Author)
+		if b.Author != nil {
+			currentBook.AppendAuthors(*b.Author)
+		}
 		if b.Tag != nil {
 			currentBook.AppendTag(*b.Tag)
 		}
-		currentBook.AppendFiles(b.FilePath, b.FileType)
+		if b.FilePath != nil {
+			file := BookFiles{FilePath: *b.FilePath}
+			if b.FileType != nil {
+				file.FileType = *b.FileType
+			}
+			if b.Checksum != nil {
+				file.Checksum = *b.Checksum
+			}
+			currentBook.appendFile(file)
+		}
+	}
+	if currentID != 0 {
+		books = append(books, currentBook)
 	}
-	books = append(books, currentBook)
 
 	return books
 }
 
-func (repo *repository) FindBooks(pattern string) ([]Book, error) {
-	query := `
+// sortKeys maps sort orders to the SQL expressions books are ordered by.
+var sortKeys = map[string][]string{
+	SortByID:       {},
+	SortByTitle:    {"b.title"},
+	SortByAuthor:   {"(SELECT MIN(a.author) FROM BookAuthors a WHERE a.bookId = b.bookId)"},
+	SortByAdded:    {"b.createDate"},
+	SortByModified: {"b.modifiedDate"},
+	SortBySeries:   {"b.series", "b.seriesIndex"},
+}
+
+// FindBooks returns books matching the search query, see parseQuery for the syntax.
+// Sorting and paging are applied to books, not to the joined rows.
+func (repo *repository) FindBooks(pattern string, opts QueryOptions) ([]Book, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = SortByID
+	}
+	keys, ok := sortKeys[sort]
+	if !ok {
+		return []Book{}, fmt.Errorf("unknown sort order: %s", sort)
+	}
+	direction := "ASC"
+	if opts.Desc {
+		direction = "DESC"
+	}
+
+	// Select the page of book ids with their sort keys, books without a
+	// value for the first key always come last.
+	var columns, order, outerOrder []string
+	for i, key := range keys {
+		columns = append(columns, fmt.Sprintf("%s AS k%d", key, i))
+		if i == 0 {
+			order = append(order, fmt.Sprintf("k%d IS NULL", i))
+			outerOrder = append(outerOrder, fmt.Sprintf("p.k%d IS NULL", i))
+		}
+		order = append(order, fmt.Sprintf("k%d %s", i, direction))
+		outerOrder = append(outerOrder, fmt.Sprintf("p.k%d %s", i, direction))
+	}
+	columns = append(columns, "b.bookId")
+	order = append(order, "b.bookId "+direction)
+	outerOrder = append(outerOrder, "p.bookId "+direction)
+
+	node, err := parseQuery(pattern)
+	if err != nil {
+		return []Book{}, err
+	}
+	var args []interface{}
+	where := ""
+	if node != nil {
+		where = "WHERE " + node.sql(&args)
+	}
+
+	limit := -1
+	if opts.Limit > 0 {
+		limit = opts.Limit
+	}
+	args = append(args, limit, opts.Offset)
+
+	query := fmt.Sprintf(`
         SELECT
-            b.bookId, b.title, b.isbn, 
+            b.bookId, b.title, b.isbn, b.asin,
+            b.publisher, b.language, b.description, b.publishDate,
+            b.series, b.seriesIndex, b.createDate, b.modifiedDate,
             ba.author,
             bt.tag,
-            bf.filePath, bf.fileType
-        FROM Books b
-            INNER JOIN BooksFts bfts ON bfts.bookId = b.bookId
-			JOIN BookFiles bf USING(bookId)
-			JOIN BookAuthors ba USING(bookId)
-            LEFT JOIN  BookTags bt USING(bookId)
-    `
-	var args []interface{}
-	if pattern != "" {
-		query += "WHERE bfts.BooksFts MATCH $1"
-		args = append(args, pattern)
-	}
+            bf.filePath, bf.fileType, bf.checksum
+        FROM (
+            SELECT %s FROM Books b
+            %s
+            ORDER BY %s
+            LIMIT $%d OFFSET $%d
+        ) p
+            JOIN Books b ON b.bookId = p.bookId
+            LEFT JOIN BookFiles bf ON bf.bookId = b.bookId
+            LEFT JOIN BookAuthors ba ON ba.bookId = b.bookId
+            LEFT JOIN  BookTags bt ON bt.bookId = b.bookId
+        ORDER BY %s
+    `,
+		strings.Join(columns, ", "),
+		where,
+		strings.Join(order, ", "),
+		len(args)-1, len(args),
+		strings.Join(outerOrder, ", "),
+	)
 
 	rows, err := repo.db.Query(query, args...)
 	if err != nil {
 		return []Book{}, fmt.Errorf("query FindBooks error: %v", err)
 	}
+	defer rows.Close()
+
 	var booksDBs []bookDB
 	for rows.Next() {
 		b := bookDB{}
-		if err := rows.Scan(&b.ID, &b.Title, &b.ISBN, &b.Author, &b.Tag, &b.FilePath, &b.FileType); err != nil {
+		if err := rows.Scan(
+			&b.ID, &b.Title, &b.ISBN, &b.ASIN,
+			&b.Publisher, &b.Language, &b.Description, &b.PublishDate,
+			&b.Series, &b.SeriesIndex, &b.CreateDate, &b.ModifiedDate,
+			&b.Author, &b.Tag, &b.FilePath, &b.FileType, &b.Checksum,
+		); err != nil {
 			return []Book{}, err
 		}
 		booksDBs = append(booksDBs, b)
 	}
+	if err := rows.Err(); err != nil {
+		return []Book{}, err
+	}
 	books := parseBooks(booksDBs)
 
 	return books, nil
@@ -316,16 +525,19 @@ func (repo *repository) getBooks(ids []int) ([]Book, error) {
 
 	query := fmt.Sprintf(`
         SELECT 
-            b.bookId, b.title, b.isbn,
+            b.bookId, b.title, b.isbn, b.asin,
+            b.publisher, b.language, b.description, b.publishDate,
+            b.series, b.seriesIndex, b.createDate, b.modifiedDate,
             ba.author,
             bt.tag,
-            bf.filePath, bf.fileType
+            bf.filePath, bf.fileType, bf.checksum
         FROM Books b
-			JOIN BookFiles bf USING(bookId)
-			JOIN BookAuthors ba USING(bookId)
+            LEFT JOIN BookFiles bf USING(bookId)
+            LEFT JOIN BookAuthors ba USING(bookId)
             LEFT JOIN  BookTags bt USING(bookId)
         WHERE
             b.bookId IN (%s)
+        ORDER BY b.bookId
 
         `, placeholders)
 
@@ -337,7 +549,12 @@ func (repo *repository) getBooks(ids []int) ([]Book, error) {
 	var booksDBs []bookDB
 	for rows.Next() {
 		b := bookDB{}
-		if err := rows.Scan(&b.ID, &b.Title, &b.ISBN, &b.Author, &b.Tag, &b.FilePath, &b.FileType); err != nil {
+		if err := rows.Scan(
+			&b.ID, &b.Title, &b.ISBN, &b.ASIN,
+			&b.Publisher, &b.Language, &b.Description, &b.PublishDate,
+			&b.Series, &b.SeriesIndex, &b.CreateDate, &b.ModifiedDate,
+			&b.Author, &b.Tag, &b.FilePath, &b.FileType, &b.Checksum,
+		); err != nil {
 			return []Book{}, err
 		}
 		booksDBs = append(booksDBs, b)
@@ -393,5 +610,480 @@ func (repo *repository) RemoveBooks(
 		return err
 	}
 
+	err = repo.deleteOrphans()
+	return err
+}
+
+// libraryFile is a book file stored in the library.
+type libraryFile struct {
+	bookID   int
+	filePath string
+}
+
+// findFilesByChecksum returns library files by their checksum.
+func (repo *repository) findFilesByChecksum(checksums []string) (map[string]libraryFile, error) {
+	files := make(map[string]libraryFile)
+	const batch = 500
+	for start := 0; start < len(checksums); start += batch {
+		end := min(start+batch, len(checksums))
+
+		placeholders := make([]string, 0, end-start)
+		args := make([]interface{}, 0, end-start)
+		for i, checksum := range checksums[start:end] {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+			args = append(args, checksum)
+		}
+
+		query := fmt.Sprintf(`
+            SELECT checksum, bookId, filePath FROM BookFiles WHERE checksum IN (%s)
+            `, strings.Join(placeholders, ", "))
+		rows, err := repo.db.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query findFilesByChecksum error: %v", err)
+		}
+		for rows.Next() {
+			var checksum string
+			var file libraryFile
+			if err := rows.Scan(&checksum, &file.bookID, &file.filePath); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			files[checksum] = file
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// UpdateBooks replaces metadata of the given books and moves their file paths.
+// fn is called inside the transaction before it is committed, rollbackFn is
+// called when the transaction is rolled back.
+func (repo *repository) UpdateBooks(
+	ctx context.Context,
+	books []*Book,
+	moves []fileMove,
+	fn func() error,
+	rollbackFn func(),
+) (err error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			rollbackFn()
+		}
+	}()
+
+	if err = repo.batchInsertSeries(ctx, tx, books); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, book := range books {
+		_, err = tx.ExecContext(ctx, `
+            UPDATE Books
+            SET
+                title = $1, isbn = $2, asin = $3, publisher = $4, language = $5,
+                description = $6, publishDate = $7, series = $8, seriesIndex = $9,
+                modifiedDate = $10
+            WHERE bookId = $11
+            `,
+			book.Title, book.ISBN, book.ASIN, book.Publisher, book.Language, book.Description, book.PublishDate,
+			nullString(book.Series), book.SeriesIndex, now, book.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("update book %d error: %v", book.ID, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, "DELETE FROM BookAuthors WHERE bookId = $1", book.ID); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM BookTags WHERE bookId = $1", book.ID); err != nil {
+			return err
+		}
+	}
+
+	if err = repo.batchInsertAuthors(ctx, tx, books); err != nil {
+		return err
+	}
+	if err = repo.batchInsertTags(ctx, tx, books); err != nil {
+		return err
+	}
+
+	// Authors and tags no longer used by any book
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Authors WHERE author NOT IN (SELECT author FROM BookAuthors)"); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Tags WHERE tag NOT IN (SELECT tag FROM BookTags)"); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Series WHERE series NOT IN (SELECT series FROM Books WHERE series IS NOT NULL)"); err != nil {
+		return err
+	}
+
+	if err = updateFilePaths(ctx, tx, moves, now); err != nil {
+		return err
+	}
+
+	err = fn()
+	return err
+}
+
+// updateFilePaths changes paths of moved book files.
+func updateFilePaths(ctx context.Context, tx *sql.Tx, moves []fileMove, now time.Time) error {
+	for _, move := range moves {
+		_, err := tx.ExecContext(ctx, `
+            UPDATE BookFiles SET filePath = $1, modifiedDate = $2 WHERE bookId = $3 AND filePath = $4
+            `, move.to, now, move.bookID, move.from)
+		if err != nil {
+			return fmt.Errorf("update book file %s error: %v", move.from, err)
+		}
+	}
+
+	return nil
+}
+
+// moveFiles changes paths of book files. fn is called inside the transaction
+// before it is committed, rollbackFn is called when the transaction is
+// rolled back.
+func (repo *repository) moveFiles(
+	ctx context.Context,
+	moves []fileMove,
+	fn func() error,
+	rollbackFn func(),
+) (err error) {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			rollbackFn()
+		}
+	}()
+
+	if err = updateFilePaths(ctx, tx, moves, time.Now()); err != nil {
+		return err
+	}
+
+	err = fn()
+	return err
+}
+
+// storedFile is a BookFiles row.
+type storedFile struct {
+	bookID   int
+	filePath string
+	checksum string
+}
+
+// allFiles returns all BookFiles rows.
+func (repo *repository) allFiles() ([]storedFile, error) {
+	rows, err := repo.db.Query("SELECT bookId, filePath, checksum FROM BookFiles ORDER BY bookId")
+	if err != nil {
+		return nil, fmt.Errorf("query allFiles error: %v", err)
+	}
+	defer rows.Close()
+
+	var files []storedFile
+	for rows.Next() {
+		var f storedFile
+		if err := rows.Scan(&f.bookID, &f.filePath, &f.checksum); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	return files, rows.Err()
+}
+
+// queryStrings returns the first column of all rows of a query.
+func (repo *repository) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+func (repo *repository) orphanAuthors() ([]string, error) {
+	return repo.queryStrings("SELECT author FROM Authors WHERE author NOT IN (SELECT author FROM BookAuthors)")
+}
+
+func (repo *repository) orphanTags() ([]string, error) {
+	return repo.queryStrings("SELECT tag FROM Tags WHERE tag NOT IN (SELECT tag FROM BookTags)")
+}
+
+func (repo *repository) orphanSeries() ([]string, error) {
+	return repo.queryStrings("SELECT series FROM Series WHERE series NOT IN (SELECT series FROM Books WHERE series IS NOT NULL)")
+}
+
+// booksWithoutFiles returns ids of books that have no BookFiles rows.
+func (repo *repository) booksWithoutFiles() ([]string, error) {
+	return repo.queryStrings("SELECT bookId FROM Books WHERE bookId NOT IN (SELECT bookId FROM BookFiles) ORDER BY bookId")
+}
+
+// ftsOutOfSync returns ids of books whose BooksFts row is missing or
+// differs from Books, and of BooksFts rows without a book.
+func (repo *repository) ftsOutOfSync() ([]string, error) {
+	type ftsRow struct{ title, isbn, series string }
+
+	books := make(map[string]ftsRow)
+	rows, err := repo.db.Query("SELECT bookId, title, isbn, IFNULL(series, '') FROM Books")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var r ftsRow
+		if err := rows.Scan(&id, &r.title, &r.isbn, &r.series); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		books[id] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	rows, err = repo.db.Query("SELECT IFNULL(bookId, ''), IFNULL(title, ''), IFNULL(isbn, ''), IFNULL(series, '') FROM BooksFts")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var r ftsRow
+		if err := rows.Scan(&id, &r.title, &r.isbn, &r.series); err != nil {
+			return nil, err
+		}
+		if book, ok := books[id]; !ok || book != r || seen[id] {
+			ids = append(ids, id)
+		}
+		seen[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for id := range books {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// deleteFile removes a BookFiles row.
+func (repo *repository) deleteFile(bookID int, filePath string) error {
+	_, err := repo.db.Exec("DELETE FROM BookFiles WHERE bookId = $1 AND filePath = $2", bookID, filePath)
+	return err
+}
+
+// setChecksum stores the checksum of a BookFiles row.
+func (repo *repository) setChecksum(bookID int, filePath string, checksum string) error {
+	_, err := repo.db.Exec(
+		"UPDATE BookFiles SET checksum = $1 WHERE bookId = $2 AND filePath = $3",
+		checksum, bookID, filePath,
+	)
+	return err
+}
+
+// deleteOrphans removes authors, tags and series no longer used by any book.
+func (repo *repository) deleteOrphans() error {
+	for _, query := range []string{
+		"DELETE FROM Authors WHERE author NOT IN (SELECT author FROM BookAuthors)",
+		"DELETE FROM Tags WHERE tag NOT IN (SELECT tag FROM BookTags)",
+		"DELETE FROM Series WHERE series NOT IN (SELECT series FROM Books WHERE series IS NOT NULL)",
+	} {
+		if _, err := repo.db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildFts recreates all BooksFts rows from Books.
+func (repo *repository) rebuildFts() error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM BooksFts"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+        INSERT INTO BooksFts(bookId, title, isbn, series, createDate, modifiedDate)
+            SELECT bookId, title, isbn, series, createDate, modifiedDate FROM Books
+        `); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// importRun is an ImportRuns row.
+type importRun struct {
+	id         int
+	source     string
+	createDate time.Time
+	finished   bool
+}
+
+// createImportRun starts a new import run of source. Earlier runs of the
+// same source and their journal are removed.
+func (repo *repository) createImportRun(source string) (importRun, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return importRun{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM ImportRuns WHERE source = $1", source); err != nil {
+		return importRun{}, fmt.Errorf("query createImportRun error: %v", err)
+	}
+	run := importRun{source: source, createDate: time.Now()}
+	res, err := tx.Exec("INSERT INTO ImportRuns (source, createDate) VALUES ($1, $2)", source, run.createDate)
+	if err != nil {
+		return importRun{}, fmt.Errorf("query createImportRun error: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return importRun{}, err
+	}
+	run.id = int(id)
+
+	return run, tx.Commit()
+}
+
+// lastImportRun returns the latest import run of source, with an id of 0 if
+// there is none.
+func (repo *repository) lastImportRun(source string) (importRun, error) {
+	run := importRun{source: source}
+	var finishDate sql.NullTime
+	err := repo.db.QueryRow(`
+        SELECT runId, createDate, finishDate FROM ImportRuns
+        WHERE source = $1 ORDER BY runId DESC LIMIT 1
+        `, source).Scan(&run.id, &run.createDate, &finishDate)
+	if err == sql.ErrNoRows {
+		return importRun{}, nil
+	}
+	if err != nil {
+		return importRun{}, fmt.Errorf("query lastImportRun error: %v", err)
+	}
+	run.finished = finishDate.Valid
+
+	return run, nil
+}
+
+// finishImportRun marks an import run as finished.
+func (repo *repository) finishImportRun(runID int) error {
+	_, err := repo.db.Exec("UPDATE ImportRuns SET finishDate = $1 WHERE runId = $2", time.Now(), runID)
+	return err
+}
+
+// journalOutcomes returns the outcome of files in the journal of a run by path.
+func (repo *repository) journalOutcomes(runID int) (map[string]Outcome, error) {
+	rows, err := repo.db.Query("SELECT filePath, outcome FROM ImportJournal WHERE runId = $1", runID)
+	if err != nil {
+		return nil, fmt.Errorf("query journalOutcomes error: %v", err)
+	}
+	defer rows.Close()
+
+	outcomes := make(map[string]Outcome)
+	for rows.Next() {
+		var path, outcome string
+		if err := rows.Scan(&path, &outcome); err != nil {
+			return nil, err
+		}
+		outcomes[path] = Outcome(outcome)
+	}
+
+	return outcomes, rows.Err()
+}
+
+// appendJournal writes results to the journal of a run in its own transaction.
+func (repo *repository) appendJournal(ctx context.Context, runID int, results []FileResult) error {
+	tx, err := repo.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeJournal(ctx, tx, runID, results); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// writeJournal writes results to the journal of a run, replacing the
+// outcome of files retried by a resumed run.
+func writeJournal(ctx context.Context, tx *sql.Tx, runID int, results []FileResult) error {
+	const batch = 500
+	for start := 0; start < len(results); start += batch {
+		end := min(start+batch, len(results))
+
+		valueStrings := make([]string, 0, end-start)
+		valueArgs := make([]interface{}, 0, (end-start)*5)
+		param := 1
+		for _, r := range results[start:end] {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", param, param+1, param+2, param+3, param+4))
+			var bookID interface{}
+			if r.BookID != 0 {
+				bookID = r.BookID
+			}
+			valueArgs = append(valueArgs, runID, r.Path, string(r.Outcome), bookID, r.Reason)
+			param += 5
+		}
+
+		query := fmt.Sprintf(`
+            INSERT OR REPLACE INTO ImportJournal (runId, filePath, outcome, bookId, reason)
+            VALUES %s
+            `, strings.Join(valueStrings, ","))
+		if _, err := tx.ExecContext(ctx, query, valueArgs...); err != nil {
+			return fmt.Errorf("query writeJournal error: %v", err)
+		}
+	}
+
 	return nil
 }
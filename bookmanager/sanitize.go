@@ -0,0 +1,241 @@
+package bookmanager
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SanitizeProfile decides which characters and lengths are allowed in file
+// and directory names created by ebm.
+type SanitizeProfile string
+
+const (
+	// SanitizePosix only replaces '/' and NUL, names are limited to 255 bytes
+	// and paths to 4095 bytes.
+	SanitizePosix SanitizeProfile = "posix"
+	// SanitizeWindows replaces characters Windows and Samba shares reject,
+	// removes trailing dots and spaces and renames reserved device names
+	// like CON or LPT1. Names are limited to 255 UTF-16 code units and
+	// paths to 259, the MAX_PATH of Windows without the terminating NUL.
+	SanitizeWindows SanitizeProfile = "windows-safe"
+	// SanitizeFAT32 applies SanitizeWindows and also replaces DEL, which
+	// FAT file systems of e-readers reject.
+	SanitizeFAT32 SanitizeProfile = "fat32"
+	// SanitizeASCII applies SanitizeFAT32 and transliterates names to ASCII,
+	// e.g. "Mañana über Ærø" becomes "Manana uber AEro".
+	SanitizeASCII SanitizeProfile = "ascii-only"
+)
+
+// maxNameLength is the maximum length of a file or directory name.
+const maxNameLength = 255
+
+// Maximum lengths of paths, including the terminating NUL.
+const (
+	maxPathPosix   = 4096 // PATH_MAX of Linux
+	maxPathWindows = 260  // MAX_PATH of Windows
+)
+
+// ParseSanitizeProfile returns the sanitize profile with the given name.
+func ParseSanitizeProfile(name string) (SanitizeProfile, error) {
+	switch p := SanitizeProfile(name); p {
+	case SanitizePosix, SanitizeWindows, SanitizeFAT32, SanitizeASCII:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown sanitize profile: %s", name)
+	}
+}
+
+// windows reports whether the profile follows Windows naming rules.
+func (p SanitizeProfile) windows() bool {
+	return p == SanitizeWindows || p == SanitizeFAT32 || p == SanitizeASCII
+}
+
+// invalid reports whether r can not be used in a name.
+func (p SanitizeProfile) invalid(r rune) bool {
+	if r == '/' || r == 0 {
+		return true
+	}
+	if !p.windows() {
+		return false
+	}
+	if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+		return true
+	}
+	return r == 0x7f && p != SanitizeWindows
+}
+
+// maxPathLength returns the maximum length of a path, as counted by
+// nameLength.
+func (p SanitizeProfile) maxPathLength() int {
+	if p.windows() {
+		return maxPathWindows - 1
+	}
+	return maxPathPosix - 1
+}
+
+// nameLength returns the length of a name as counted by the file system.
+func (p SanitizeProfile) nameLength(name string) int {
+	if p.windows() {
+		return len(utf16.Encode([]rune(name)))
+	}
+	return len(name)
+}
+
+// transliterations are ASCII replacements of letters that do not decompose
+// into an ASCII letter and combining marks.
+var transliterations = map[rune]string{
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'Ø': "O", 'ø': "o",
+	'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d", 'Ð': "D", 'ð': "d", 'Þ': "Th",
+	'þ': "th", 'ı': "i", '‘': "'", '’': "'", '“': "'", '”': "'", '–': "-",
+	'—': "-", '…': "...", '«': "'", '»': "'",
+}
+
+// transliterate returns s in ASCII, other characters are replaced by '_'.
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// combining mark of a decomposed letter
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// windowsReserved are device names Windows does not allow as names, with
+// or without extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// replaceInvalid replaces characters that can not be used in a name,
+// including '/', so the value stays in one path segment.
+func (p SanitizeProfile) replaceInvalid(value string) string {
+	if p == SanitizeASCII {
+		value = transliterate(value)
+	}
+	return strings.Map(func(r rune) rune {
+		if p.invalid(r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// name returns a valid file or directory name for value, of at most limit.
+// The name is truncated so that suffix fits, suffix is appended as is.
+func (p SanitizeProfile) name(value string, suffix string, limit int) string {
+	value = strings.TrimSpace(p.replaceInvalid(value))
+	if p.windows() {
+		value = strings.TrimRight(value, ". ")
+	}
+	limit = min(limit, maxNameLength) - p.nameLength(suffix)
+	if p.windows() {
+		limit-- // room for the '_' of reserved names
+	}
+	value = p.truncate(value, limit)
+
+	if p.windows() {
+		value = strings.TrimRight(value, ". ")
+		base, _, _ := strings.Cut(value+suffix, ".")
+		if windowsReserved[strings.ToUpper(strings.TrimSpace(base))] {
+			value = "_" + value
+		}
+	}
+	if value == "" || value == "." || value == ".." {
+		value = strings.Repeat("_", max(len(value), 1))
+	}
+
+	return value + suffix
+}
+
+// truncate shortens value to at most n, without splitting characters.
+func (p SanitizeProfile) truncate(value string, n int) string {
+	for p.nameLength(value) > n && value != "" {
+		_, size := utf8.DecodeLastRuneInString(value)
+		value = value[:len(value)-size]
+	}
+	return strings.TrimSpace(value)
+}
+
+// fileName returns a valid file name for stem and ext of at most limit, with
+// the collision number n in the name if n > 1, e.g. "Title (2).epub". The
+// stem is truncated, the extension is kept.
+func (p SanitizeProfile) fileName(stem string, ext string, n int, limit int) string {
+	suffix := p.replaceInvalid(ext)
+	if n > 1 {
+		suffix = fmt.Sprintf(" (%d)%s", n, suffix)
+	}
+	return p.name(stem, suffix, limit)
+}
+
+// uniquePath returns path, or path with the lowest collision number for
+// which taken returns false. path is a file below root. Its file name and the
+// directories below root are sanitized and truncated, the longest first, so
+// that the path, and the path of a cover next to it, fit the maximum path
+// length of the profile. The extension is kept. An error is returned if root
+// leaves no room for names below it.
+func (p SanitizeProfile) uniquePath(root, path string, taken func(path string) bool) (string, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not below %s", path, root)
+	}
+	segments := strings.Split(rel, string(filepath.Separator))
+	dirs, base := segments[:len(segments)-1], segments[len(segments)-1]
+	ext := FileExt(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	extLength := len(ext)
+	for _, coverExt := range coverExts {
+		extLength = max(extLength, len(coverExt))
+	}
+	// Room below root, after the separator following it
+	limit := p.maxPathLength() - p.nameLength(filepath.Clean(root)) - 1 - extLength + len(ext)
+	for n := 1; ; n++ {
+		rel, ok := p.fitPath(dirs, stem, ext, n, limit)
+		if !ok {
+			return "", fmt.Errorf("%s leaves no room for file names within the maximum path length %d of %s", root, p.maxPathLength(), p)
+		}
+		candidate := filepath.Join(root, rel)
+		if !taken(candidate) {
+			return candidate, nil
+		}
+	}
+}
+
+// fitPath returns the relative path of dirs and of the file name of stem, ext
+// and collision number n, with the longest names cut to the same length so
+// that the path is at most limit long. A file name keeps at least one
+// character of its stem. ok is false if the shortest names do not fit.
+func (p SanitizeProfile) fitPath(dirs []string, stem string, ext string, n int, limit int) (string, bool) {
+	shortest := p.nameLength(p.fileName("", ext, n, maxNameLength))
+	names := make([]string, len(dirs)+1)
+	for cut := maxNameLength; cut > 0; cut-- {
+		for i, dir := range dirs {
+			names[i] = p.name(dir, "", cut)
+		}
+		names[len(dirs)] = p.fileName(stem, ext, n, max(cut, shortest))
+		if rel := filepath.Join(names...); p.nameLength(rel) <= limit {
+			return rel, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,169 @@
+package bookmanager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile SanitizeProfile
+		value   string
+		suffix  string
+		want    string
+	}{
+		{name: "posix separators", profile: SanitizePosix, value: "a/b\x00c", want: "a_b_c"},
+		{name: "posix keeps windows characters", profile: SanitizePosix, value: `a:b?"c". `, want: `a:b?"c".`},
+		{name: "posix keeps reserved names", profile: SanitizePosix, value: "CON", want: "CON"},
+		{name: "posix dot", profile: SanitizePosix, value: ".", want: "_"},
+		{name: "posix dot dot", profile: SanitizePosix, value: "..", want: "__"},
+		{name: "empty", profile: SanitizePosix, value: " ", want: "_"},
+		{name: "windows characters", profile: SanitizeWindows, value: "a<b>c:d\"e\\f|g?h*i\x01j", want: "a_b_c_d_e_f_g_h_i_j"},
+		{name: "windows trailing dots and spaces", profile: SanitizeWindows, value: "Title. . ", want: "Title"},
+		{name: "windows dots only", profile: SanitizeWindows, value: "...", want: "_"},
+		{name: "windows keeps DEL", profile: SanitizeWindows, value: "a\x7fb", want: "a\x7fb"},
+		{name: "windows reserved", profile: SanitizeWindows, value: "CON", want: "_CON"},
+		{name: "windows reserved lower case", profile: SanitizeWindows, value: "lpt1", want: "_lpt1"},
+		{name: "windows reserved with extension", profile: SanitizeWindows, value: "nul", suffix: ".epub", want: "_nul.epub"},
+		{name: "windows reserved with dot", profile: SanitizeWindows, value: "Com1.tar", suffix: ".gz", want: "_Com1.tar.gz"},
+		{name: "windows not reserved", profile: SanitizeWindows, value: "CONSOLE", want: "CONSOLE"},
+		{name: "windows not reserved number", profile: SanitizeWindows, value: "COM10", want: "COM10"},
+		{name: "fat32 DEL", profile: SanitizeFAT32, value: "a\x7fb", want: "a_b"},
+		{name: "fat32 reserved", profile: SanitizeFAT32, value: "aux", want: "_aux"},
+		{name: "ascii transliteration", profile: SanitizeASCII, value: "Mañana über Ærø", want: "Manana uber AEro"},
+		{name: "ascii punctuation", profile: SanitizeASCII, value: "“Straße” – Łódź… Ø", want: "'Strasse' - Lodz... O"},
+		{name: "ascii untransliterated", profile: SanitizeASCII, value: "Война и мир", want: "_____ _ ___"},
+		{name: "ascii reserved", profile: SanitizeASCII, value: "prn", want: "_prn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.name(tt.value, tt.suffix, maxNameLength); got != tt.want {
+				t.Errorf("name(%q, %q) = %q, want %q", tt.value, tt.suffix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile SanitizeProfile
+		value   string
+		ext     string
+		n       int
+		want    string
+	}{
+		{name: "posix bytes", profile: SanitizePosix, value: strings.Repeat("a", 300), ext: ".epub", n: 1, want: strings.Repeat("a", 250) + ".epub"},
+		{name: "posix whole characters", profile: SanitizePosix, value: strings.Repeat("é", 200), ext: ".epub", n: 1, want: strings.Repeat("é", 125) + ".epub"},
+		{name: "windows code units", profile: SanitizeWindows, value: strings.Repeat("é", 300), ext: ".epub", n: 1, want: strings.Repeat("é", 249) + ".epub"},
+		{name: "windows surrogate pairs", profile: SanitizeWindows, value: strings.Repeat("😀", 200), ext: ".pdf", n: 1, want: strings.Repeat("😀", 125) + ".pdf"},
+		{name: "trailing space after cut", profile: SanitizePosix, value: strings.Repeat("a", 249) + " b", ext: ".epub", n: 1, want: strings.Repeat("a", 249) + ".epub"},
+		{name: "collision number", profile: SanitizePosix, value: "Title", ext: ".epub", n: 2, want: "Title (2).epub"},
+		{name: "collision number of a long name", profile: SanitizePosix, value: strings.Repeat("a", 300), ext: ".epub", n: 12, want: strings.Repeat("a", 245) + " (12).epub"},
+		{name: "extension sanitized", profile: SanitizeWindows, value: "Title", ext: ".a?b", n: 1, want: "Title.a_b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.fileName(tt.value, tt.ext, tt.n, maxNameLength); got != tt.want {
+				t.Errorf("fileName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	root := filepath.FromSlash("/library")
+	long := strings.Repeat("a", 200)
+
+	tests := []struct {
+		name    string
+		profile SanitizeProfile
+		root    string
+		path    string
+		taken   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "free",
+			profile: SanitizePosix,
+			root:    root,
+			path:    "Author/Title/Title.epub",
+			want:    "Author/Title/Title.epub",
+		},
+		{
+			name:    "collision numbers",
+			profile: SanitizePosix,
+			root:    root,
+			path:    "Author/Title.epub",
+			taken:   []string{"Author/Title.epub", "Author/Title (2).epub"},
+			want:    "Author/Title (3).epub",
+		},
+		{
+			name:    "posix path fits",
+			profile: SanitizePosix,
+			root:    root,
+			path:    long + "/" + long + "/" + long + ".epub",
+			want:    long + "/" + long + "/" + long + ".epub",
+		},
+		{
+			// 259 - len("/library/") leaves 250, covers have extensions up to
+			// ".webp", names of 81 and the short one fit with separators
+			name:    "windows longest names cut first",
+			profile: SanitizeWindows,
+			root:    root,
+			path:    long + "/bb/" + long + "/" + long + ".epub",
+			want:    long[:81] + "/bb/" + long[:81] + "/" + long[:76] + ".epub",
+		},
+		{
+			name:    "windows collision number within the path",
+			profile: SanitizeWindows,
+			root:    root,
+			path:    long + "/" + long + ".epub",
+			taken:   []string{long[:124] + "/" + long[:119] + ".epub"},
+			want:    long[:124] + "/" + long[:115] + " (2).epub",
+		},
+		{
+			name:    "root leaves no room",
+			profile: SanitizeWindows,
+			root:    "/" + strings.Repeat("r", 250),
+			path:    "Author/Title/Title.epub",
+			wantErr: true,
+		},
+		{
+			name:    "not below root",
+			profile: SanitizePosix,
+			root:    root,
+			path:    "../Title.epub",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := make(map[string]bool)
+			for _, path := range tt.taken {
+				taken[filepath.Join(tt.root, filepath.FromSlash(path))] = true
+			}
+			got, err := tt.profile.uniquePath(tt.root, filepath.Join(tt.root, filepath.FromSlash(tt.path)), func(path string) bool {
+				return taken[path]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("uniquePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if want := filepath.Join(tt.root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("uniquePath() = %q, want %q", got, want)
+			}
+			if length := tt.profile.nameLength(got) + len(".webp") - len(".epub"); length > tt.profile.maxPathLength() {
+				t.Errorf("uniquePath() length with a cover = %d, want at most %d", length, tt.profile.maxPathLength())
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package bookmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// TransferMode decides how ImportBooks puts files into the ebm directory.
+type TransferMode string
+
+const (
+	// TransferCopy copies files, the default.
+	TransferCopy TransferMode = "copy"
+	// TransferMove moves files. Files on another file system are copied and
+	// the source is removed once the import is committed.
+	TransferMove TransferMode = "move"
+	// TransferHardlink links files, ImportOptions.Fallback is used when the
+	// source is on another file system.
+	TransferHardlink TransferMode = "hardlink"
+	// TransferSymlink creates symbolic links to the absolute source path.
+	TransferSymlink TransferMode = "symlink"
+	// TransferReflink clones files sharing their blocks on file systems with
+	// copy on write like Btrfs or XFS, ImportOptions.Fallback is used when
+	// that is not possible.
+	TransferReflink TransferMode = "reflink"
+)
+
+// ParseTransferMode returns the transfer mode with the given name.
+func ParseTransferMode(name string) (TransferMode, error) {
+	switch m := TransferMode(name); m {
+	case TransferCopy, TransferMove, TransferHardlink, TransferSymlink, TransferReflink:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown transfer mode: %s", name)
+	}
+}
+
+// transfer is a file put into the ebm directory by an import.
+type transfer struct {
+	src  string
+	dst  string
+	mode TransferMode // mode used, after fallback
+	// removeSource is set when a file moved across file systems was copied,
+	// the source is removed after the import is committed.
+	removeSource bool
+}
+
+// linkNotPossible reports whether a hardlink or reflink failed because the
+// file systems do not allow it, rather than because of the files.
+func linkNotPossible(err error) bool {
+	return errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EMLINK) ||
+		errors.Is(err, syscall.ENOTSUP) ||
+		errors.Is(err, errors.ErrUnsupported)
+}
+
+// transferFile puts src at dst with the given mode and returns what was
+// done. The checksum is only returned by modes that read the content,
+// dst is never overwritten.
+func transferFile(src, dst string, mode TransferMode, fallback TransferMode) (transfer, string, error) {
+	t := transfer{src: src, dst: dst, mode: mode}
+	switch mode {
+	case TransferMove:
+		// Link then remove, os.Rename would replace an existing dst
+		err := os.Link(src, dst)
+		if errors.Is(err, syscall.EXDEV) {
+			t.mode = TransferCopy
+			t.removeSource = true
+			checksum, err := copyFileWithChecksum(src, dst)
+			return t, checksum, err
+		}
+		if linkNotPossible(err) {
+			if fileExists(dst) {
+				return t, "", &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+			}
+			return t, "", os.Rename(src, dst)
+		}
+		if err != nil {
+			return t, "", err
+		}
+		if err := os.Remove(src); err != nil {
+			os.Remove(dst)
+			return t, "", err
+		}
+		return t, "", nil
+	case TransferHardlink, TransferReflink:
+		var err error
+		if mode == TransferHardlink {
+			err = os.Link(src, dst)
+		} else {
+			err = reflink(src, dst)
+		}
+		if linkNotPossible(err) {
+			return transferFile(src, dst, fallback, TransferCopy)
+		}
+		return t, "", err
+	case TransferSymlink:
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return t, "", err
+		}
+		return t, "", os.Symlink(abs, dst)
+	default:
+		t.mode = TransferCopy
+		checksum, err := copyFileWithChecksum(src, dst)
+		return t, checksum, err
+	}
+}
+
+// undo reverts a transfer: moved files are moved back, other files are removed.
+func (t transfer) undo() error {
+	if t.mode == TransferMove {
+		return os.Rename(t.dst, t.src)
+	}
+	return os.Remove(t.dst)
+}
+
+// undoTransfers reverts transfers in reverse order.
+func undoTransfers(transfers []transfer) {
+	for i := len(transfers) - 1; i >= 0; i-- {
+		transfers[i].undo()
+	}
+}
+
+// transferFileUnique puts src at dst, or at dst with the lowest free
+// collision number, shortened to fit the maximum path length. Directories
+// are created as needed.
+func (b *BookManager) transferFileUnique(src, dst string, opts ImportOptions) (transfer, string, error) {
+	for {
+		path, err := b.sanitize.uniquePath(b.directory, dst, fileExists)
+		if err != nil {
+			return transfer{}, "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return transfer{dst: path}, "", err
+		}
+		t, checksum, err := transferFile(src, path, opts.Mode, opts.Fallback)
+		if os.IsExist(err) {
+			// created by another worker since uniquePath
+			continue
+		}
+		return t, checksum, err
+	}
+}
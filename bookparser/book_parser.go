@@ -1,14 +1,19 @@
 package bookparser
 
 import (
+	"archive/zip"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 )
 
 var (
 	ErrNotSupportMimeType = errors.New("unsupported mime type")
+	ErrNoCover            = errors.New("no cover")
 )
 
 // File consist of file information such as filetype (pdf, epub) and path.
@@ -19,11 +24,19 @@ type File struct {
 
 // Metadata consist of ebook metadata.
 type Metadata struct {
-	ISBN      string
-	Title     string
-	Authors   []string
-	Publisher string
-	Tags      []string
+	ISBN        string
+	ASIN        string // Amazon identifier of Kindle books
+	Title       string
+	Authors     []string
+	Publisher   string
+	Language    string
+	Description string
+	PublishDate string // YYYY-MM-DD, YYYY-MM or YYYY
+	Series      string
+	SeriesIndex float64
+	Tags        []string
+	Cover       string // image in the book file used as cover, the archive entry or MOBI record, see ReadCover
+	FileTitle   bool   // Title is the file name, the file has no title
 }
 
 // BookParser is an instance of book info, consist of ebook metadata and file information.
@@ -38,9 +51,9 @@ func Parse(path string) (BookParser, error) {
 	if err != nil {
 		return BookParser{}, err
 	}
+	defer f.Close()
 	fi, err := f.Stat()
 	if err != nil {
-		f.Close()
 		return BookParser{}, err
 	}
 
@@ -53,6 +66,30 @@ func Parse(path string) (BookParser, error) {
 			return BookParser{}, err
 		}
 
+		reader.Metadata = metadata
+	} else if isFB2(f, fi.Size()) {
+		reader.File.Type = "fb2"
+		metadata, err := parseMetadataFromFB2(path)
+		if err != nil {
+			return BookParser{}, err
+		}
+
+		reader.Metadata = metadata
+	} else if isDjvu(f, fi.Size()) {
+		reader.File.Type = "djvu"
+		metadata, err := parseMetadataFromDjvu(path)
+		if err != nil {
+			return BookParser{}, err
+		}
+
+		reader.Metadata = metadata
+	} else if fileType := officeType(f, fi.Size()); fileType != "" {
+		reader.File.Type = fileType
+		metadata, err := parseMetadataFromOffice(path, fileType)
+		if err != nil {
+			return BookParser{}, err
+		}
+
 		reader.Metadata = metadata
 	} else if isEpub(f, fi.Size()) {
 		reader.File.Type = "epub"
@@ -61,14 +98,46 @@ func Parse(path string) (BookParser, error) {
 			return BookParser{}, err
 		}
 
+		reader.Metadata = metadata
+	} else if fileType := comicType(f, fi.Size(), path); fileType != "" {
+		reader.File.Type = fileType
+		metadata, err := parseMetadataFromComic(path, fileType)
+		if err != nil {
+			return BookParser{}, err
+		}
+
 		reader.Metadata = metadata
 	} else if isMobi(f, fi.Size()) {
-		reader.File.Type = "mobi"
+		reader.File.Type = mobiFileType(f, fi.Size())
 		metadata, err := parseMetadataFromMobi(path)
 		if err != nil {
 			return BookParser{}, err
 		}
 
+		reader.Metadata = metadata
+	} else if isRTF(f, fi.Size()) {
+		reader.File.Type = "rtf"
+		metadata, err := parseMetadataFromRTF(path)
+		if err != nil {
+			return BookParser{}, err
+		}
+
+		reader.Metadata = metadata
+	} else if isHTML(f, path) {
+		reader.File.Type = "html"
+		metadata, err := parseMetadataFromHTML(path)
+		if err != nil {
+			return BookParser{}, err
+		}
+
+		reader.Metadata = metadata
+	} else if isText(f, path) {
+		reader.File.Type = "txt"
+		metadata, err := parseMetadataFromText(path)
+		if err != nil {
+			return BookParser{}, err
+		}
+
 		reader.Metadata = metadata
 	} else {
 		return BookParser{}, ErrNotSupportMimeType
@@ -77,6 +146,22 @@ func Parse(path string) (BookParser, error) {
 	return reader, nil
 }
 
+// ReadCover returns the image of Metadata.Cover, ErrNoCover if the book has
+// no cover or the cover can not be read from its file type.
+func (p BookParser) ReadCover() ([]byte, error) {
+	if p.Metadata.Cover == "" {
+		return nil, ErrNoCover
+	}
+	switch p.File.Type {
+	case "cbz", "cbr", "cb7":
+		return readArchiveEntry(p.File.Path, p.File.Type, p.Metadata.Cover)
+	case "mobi", "azw3":
+		return readMobiCover(p.File.Path, p.Metadata.Cover)
+	default:
+		return nil, ErrNoCover
+	}
+}
+
 // isPDF check is mime type pdf.
 func isPDF(f io.ReaderAt, size int64) bool {
 	if size < 10 {
@@ -90,10 +175,40 @@ func isPDF(f io.ReaderAt, size int64) bool {
 	return true
 }
 
-// isEpub check is mime type epub.
+// isEpub check is mime type epub, a zip archive with the epub mimetype file
+// or an OPF container.
 func isEpub(f io.ReaderAt, size int64) bool {
+	if !isZip(f, size) {
+		return false
+	}
+	r, err := zip.NewReader(f, size)
+	if err != nil {
+		return false
+	}
+	for _, file := range r.File {
+		switch file.Name {
+		case "META-INF/container.xml":
+			return true
+		case "mimetype":
+			rc, err := file.Open()
+			if err != nil {
+				continue
+			}
+			buf := make([]byte, 64)
+			n, _ := io.ReadFull(rc, buf)
+			rc.Close()
+			if strings.TrimSpace(string(buf[:n])) == "application/epub+zip" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isZip check does the file start with a zip local file header.
+func isZip(f io.ReaderAt, size int64) bool {
 	if size < 4 {
-		return false // File to small to be a valid Epub
+		return false // File to small to be a valid zip
 	}
 	buf := make([]byte, 4)
 	f.ReadAt(buf, 0)
@@ -122,6 +237,12 @@ func isMobi(f io.ReaderAt, size int64) bool {
 	return bytes.Equal(bookMarker, []byte("BOOK")) && bytes.Equal(mobiMarker, []byte("MOBI"))
 }
 
+// fileNameMetadata returns the metadata of a file without readable
+// metadata, titled by its file name.
+func fileNameMetadata(path string) Metadata {
+	return Metadata{Title: getTitleFromFilePath(path), FileTitle: true}
+}
+
 func getTitleFromFilePath(filePath string) string {
 	title := ""
 	for i := len(filePath) - 1; i > 0; i-- {
@@ -138,3 +259,38 @@ func getTitleFromFilePath(filePath string) string {
 
 	return title
 }
+
+// parseSeriesIndex parses a series index such as "2" or "2.5", returns 0 if invalid.
+func parseSeriesIndex(index string) float64 {
+	i, err := strconv.ParseFloat(strings.TrimSpace(index), 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// normalizeDate converts dates such as "2006-01-02T15:04:05Z" or PDF dates
+// "D:20060102150405" to YYYY-MM-DD, keeping only the parts that are present.
+func normalizeDate(date string) string {
+	date = strings.TrimPrefix(strings.TrimSpace(date), "D:")
+	digits := []byte{}
+	for i := 0; i < len(date) && len(digits) < 8; i++ {
+		c := date[i]
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		} else if c != '-' || len(digits)%2 != 0 || len(digits) < 4 {
+			break
+		}
+	}
+
+	switch {
+	case len(digits) >= 8:
+		return fmt.Sprintf("%s-%s-%s", digits[:4], digits[4:6], digits[6:8])
+	case len(digits) >= 6:
+		return fmt.Sprintf("%s-%s", digits[:4], digits[4:6])
+	case len(digits) >= 4:
+		return string(digits[:4])
+	default:
+		return ""
+	}
+}
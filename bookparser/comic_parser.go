@@ -0,0 +1,354 @@
+package bookparser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode/v2"
+)
+
+// comicInfo is the ComicInfo.xml metadata of a comic book archive.
+type comicInfo struct {
+	Title       string `xml:"Title"`
+	Series      string `xml:"Series"`
+	Number      string `xml:"Number"`
+	Summary     string `xml:"Summary"`
+	Year        int    `xml:"Year"`
+	Month       int    `xml:"Month"`
+	Day         int    `xml:"Day"`
+	Writer      string `xml:"Writer"`
+	Penciller   string `xml:"Penciller"`
+	Publisher   string `xml:"Publisher"`
+	Genre       string `xml:"Genre"`
+	Tags        string `xml:"Tags"`
+	LanguageISO string `xml:"LanguageISO"`
+	GTIN        string `xml:"GTIN"`
+}
+
+// maxArchiveEntrySize is the largest archive entry read, a page or ComicInfo.xml.
+const maxArchiveEntrySize = 64 << 20
+
+var (
+	rarMagic      = []byte("Rar!\x1a\x07")
+	sevenZipMagic = []byte("7z\xbc\xaf\x27\x1c")
+
+	comicExts = map[string]bool{".cbz": true, ".cbr": true, ".cb7": true}
+	imageExts = map[string]bool{
+		".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+		".webp": true, ".bmp": true, ".avif": true, ".jxl": true,
+	}
+
+	// comicYear matches a year in parentheses, e.g. "Saga 001 (2012)".
+	comicYear = regexp.MustCompile(`\((\d{4})\)`)
+	// comicTags matches groups in parentheses or brackets, e.g. "(digital)".
+	comicTags = regexp.MustCompile(`\s*(\([^)]*\)|\[[^\]]*\])`)
+	// comicNumber matches the issue number at the end of a name, e.g. "Saga 001" or "Saga #1".
+	comicNumber = regexp.MustCompile(`^(.*?)[\s_-]*#?(\d+(?:\.\d+)?)$`)
+)
+
+// comicType returns the file type of a comic book archive, "cbz", "cbr" or
+// "cb7", empty if the file is not a comic. The type is given by the content, a
+// zip archive named .cbr is a cbz. Zip archives are comics if they have a
+// comic extension or contain only images, RAR and 7z archives only if they
+// have a comic extension.
+func comicType(f io.ReaderAt, size int64, filePath string) string {
+	comicExt := comicExts[strings.ToLower(filepath.Ext(filePath))]
+	buf := make([]byte, 6)
+	if size < int64(len(buf)) {
+		return ""
+	}
+	f.ReadAt(buf, 0)
+
+	switch {
+	case isZip(f, size):
+		r, err := zip.NewReader(f, size)
+		if err != nil {
+			return ""
+		}
+		names := []string{}
+		for _, file := range r.File {
+			names = append(names, file.Name)
+		}
+		if comicExt || isImageArchive(names) {
+			return "cbz"
+		}
+	case comicExt && bytes.Equal(buf, rarMagic):
+		return "cbr"
+	case comicExt && bytes.Equal(buf, sevenZipMagic):
+		return "cb7"
+	}
+	return ""
+}
+
+// isImageArchive reports whether all files of an archive are images, besides
+// ComicInfo.xml and hidden files.
+func isImageArchive(names []string) bool {
+	images := 0
+	for _, name := range names {
+		if strings.HasSuffix(name, "/") || isHiddenEntry(name) || strings.EqualFold(path.Base(name), "ComicInfo.xml") {
+			continue
+		}
+		if !imageExts[strings.ToLower(path.Ext(name))] {
+			return false
+		}
+		images++
+	}
+	return images > 0
+}
+
+// isHiddenEntry reports whether an archive entry is a hidden or system file,
+// such as __MACOSX/._001.jpg or Thumbs.db.
+func isHiddenEntry(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" || strings.EqualFold(part, "Thumbs.db") {
+			return true
+		}
+	}
+	return false
+}
+
+// comicImages returns the images of an archive in page order.
+func comicImages(names []string) []string {
+	images := []string{}
+	for _, name := range names {
+		if !isHiddenEntry(name) && imageExts[strings.ToLower(path.Ext(name))] {
+			images = append(images, name)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return strings.ToLower(images[i]) < strings.ToLower(images[j])
+	})
+	return images
+}
+
+// readComicInfo decodes the ComicInfo.xml of a comic book archive with the
+// files names, ok is false if there is none.
+func readComicInfo(filePath string, fileType string, names []string) (info comicInfo, ok bool, err error) {
+	for _, name := range names {
+		if isHiddenEntry(name) || !strings.EqualFold(path.Base(name), "ComicInfo.xml") {
+			continue
+		}
+		data, err := readArchiveEntry(filePath, fileType, name)
+		if err != nil {
+			return info, false, err
+		}
+
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		decoder.Entity = xml.HTMLEntity
+		if err := decoder.Decode(&info); err != nil {
+			return info, false, err
+		}
+		return info, true, nil
+	}
+	return info, false, nil
+}
+
+// comicFromFileName returns the metadata given by the file name of a comic,
+// e.g. series "Saga", number 1 and year 2012 for "Saga 001 (2012) (digital).cbz".
+func comicFromFileName(filePath string) Metadata {
+	base := filepath.Base(filePath)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	metadata := Metadata{Title: stem, Authors: []string{}, Tags: []string{}, FileTitle: true}
+	if m := comicYear.FindStringSubmatch(stem); m != nil {
+		metadata.PublishDate = m[1]
+	}
+	name := strings.TrimSpace(comicTags.ReplaceAllString(stem, ""))
+	if name != "" {
+		metadata.Title = name
+	}
+	if m := comicNumber.FindStringSubmatch(name); m != nil && m[1] != "" {
+		metadata.Series = m[1]
+		metadata.SeriesIndex = parseSeriesIndex(m[2])
+	}
+	return metadata
+}
+
+// splitList splits a comma separated list of ComicInfo.xml, appending the
+// values missing in list.
+func splitList(list []string, values string) []string {
+	for _, value := range strings.Split(values, ",") {
+		value = strings.TrimSpace(value)
+		found := value == ""
+		for _, v := range list {
+			found = found || v == value
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// parseMetadataFromComic reads the metadata of a comic book archive from its
+// ComicInfo.xml, fallback to the file name. The cover is the first image.
+func parseMetadataFromComic(filePath string, fileType string) (Metadata, error) {
+	metadata := comicFromFileName(filePath)
+
+	names, err := archiveEntries(filePath, fileType)
+	if err != nil {
+		return metadata, nil
+	}
+
+	if images := comicImages(names); len(images) > 0 {
+		metadata.Cover = images[0]
+	}
+
+	info, ok, err := readComicInfo(filePath, fileType, names)
+	if err != nil || !ok {
+		return metadata, nil
+	}
+
+	if title := strings.TrimSpace(info.Title); title != "" {
+		metadata.Title = title
+		metadata.FileTitle = false
+	}
+	if series := strings.TrimSpace(info.Series); series != "" {
+		metadata.Series = series
+		metadata.SeriesIndex = parseSeriesIndex(info.Number)
+	}
+	metadata.Authors = splitList(splitList([]string{}, info.Writer), info.Penciller)
+	metadata.Tags = splitList(splitList([]string{}, info.Genre), info.Tags)
+	metadata.Publisher = strings.TrimSpace(info.Publisher)
+	metadata.Description = strings.TrimSpace(info.Summary)
+	metadata.Language = strings.TrimSpace(info.LanguageISO)
+	metadata.ISBN = strings.TrimSpace(info.GTIN)
+	switch {
+	case info.Year > 0 && info.Month > 0 && info.Day > 0:
+		metadata.PublishDate = fmt.Sprintf("%04d-%02d-%02d", info.Year, info.Month, info.Day)
+	case info.Year > 0 && info.Month > 0:
+		metadata.PublishDate = fmt.Sprintf("%04d-%02d", info.Year, info.Month)
+	case info.Year > 0:
+		metadata.PublishDate = fmt.Sprintf("%04d", info.Year)
+	}
+
+	return metadata, nil
+}
+
+// archiveEntries returns the names of the files of a comic book archive of
+// file type "cbz", "cbr" or "cb7".
+func archiveEntries(filePath string, fileType string) ([]string, error) {
+	names := []string{}
+	switch fileType {
+	case "cbz":
+		zr, err := zip.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, file := range zr.File {
+			if !file.FileInfo().IsDir() {
+				names = append(names, file.Name)
+			}
+		}
+	case "cbr":
+		rr, err := rardecode.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer rr.Close()
+		for {
+			header, err := rr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !header.IsDir {
+				names = append(names, header.Name)
+			}
+		}
+	case "cb7":
+		sr, err := openSevenZip(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer sr.Close()
+		for _, file := range sr.File {
+			if !file.FileInfo().IsDir() {
+				names = append(names, file.Name)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("not a comic book archive: %s", fileType)
+	}
+	return names, nil
+}
+
+// openSevenZip opens a 7z archive. The 7z reader panics on some malformed
+// headers, which is returned as an error.
+func openSevenZip(filePath string) (r *sevenzip.ReadCloser, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			r, err = nil, fmt.Errorf("invalid 7z archive: %v", v)
+		}
+	}()
+	return sevenzip.OpenReader(filePath)
+}
+
+// readArchiveEntry returns the content of the file name in a comic book
+// archive of file type "cbz", "cbr" or "cb7".
+func readArchiveEntry(filePath string, fileType string, name string) ([]byte, error) {
+	switch fileType {
+	case "cbz":
+		zr, err := zip.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, file := range zr.File {
+			if file.Name == name {
+				return readEntry(file, maxArchiveEntrySize)
+			}
+		}
+	case "cbr":
+		rr, err := rardecode.OpenReader(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer rr.Close()
+		for {
+			header, err := rr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Name == name && !header.IsDir {
+				return io.ReadAll(io.LimitReader(rr, maxArchiveEntrySize))
+			}
+		}
+	case "cb7":
+		sr, err := openSevenZip(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer sr.Close()
+		for _, file := range sr.File {
+			if file.Name != name {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, maxArchiveEntrySize))
+		}
+	default:
+		return nil, fmt.Errorf("not a comic book archive: %s", fileType)
+	}
+	return nil, fs.ErrNotExist
+}
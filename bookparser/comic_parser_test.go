@@ -0,0 +1,150 @@
+package bookparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadataFromComic(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		entries []zipEntry
+		want    Metadata
+	}{
+		{
+			name: "comic info",
+			file: "saga-1.cbz",
+			entries: []zipEntry{
+				{"Saga/002.jpg", "page"},
+				{"Saga/001.JPG", "cover"},
+				{"__MACOSX/Saga/._000.jpg", "resource fork"},
+				{"Saga/ComicInfo.xml", `<?xml version="1.0"?>
+<ComicInfo>
+	<Title> Chapter One </Title>
+	<Series>Saga</Series>
+	<Number>1</Number>
+	<Summary>When two soldiers fall in love&hellip;</Summary>
+	<Year>2012</Year>
+	<Month>3</Month>
+	<Day>14</Day>
+	<Writer>Brian K. Vaughan</Writer>
+	<Penciller>Fiona Staples, Brian K. Vaughan</Penciller>
+	<Publisher>Image</Publisher>
+	<Genre>Science Fiction, Fantasy</Genre>
+	<Tags>space opera,Fantasy</Tags>
+	<LanguageISO>en</LanguageISO>
+	<GTIN>9781607066019</GTIN>
+</ComicInfo>`},
+			},
+			want: Metadata{
+				ISBN:        "9781607066019",
+				Title:       "Chapter One",
+				Authors:     []string{"Brian K. Vaughan", "Fiona Staples"},
+				Publisher:   "Image",
+				Language:    "en",
+				Description: "When two soldiers fall in love…",
+				PublishDate: "2012-03-14",
+				Series:      "Saga",
+				SeriesIndex: 1,
+				Tags:        []string{"Science Fiction", "Fantasy", "space opera"},
+				Cover:       "Saga/001.JPG",
+			},
+		},
+		{
+			name: "comic info without title and day",
+			file: "Saga 001 (2012) (digital).cbz",
+			entries: []zipEntry{
+				{"001.png", "cover"},
+				{"ComicInfo.xml", `<ComicInfo><Year>2012</Year><Month>11</Month></ComicInfo>`},
+			},
+			want: Metadata{
+				Title:       "Saga 001",
+				Authors:     []string{},
+				PublishDate: "2012-11",
+				Series:      "Saga",
+				SeriesIndex: 1,
+				Tags:        []string{},
+				Cover:       "001.png",
+				FileTitle:   true,
+			},
+		},
+		{
+			name:    "file name",
+			file:    "Sandman #5.1 (1989) [scan].cbz",
+			entries: []zipEntry{{"p1.jpg", "cover"}},
+			want: Metadata{
+				Title:       "Sandman #5.1",
+				Authors:     []string{},
+				PublishDate: "1989",
+				Series:      "Sandman",
+				SeriesIndex: 5.1,
+				Tags:        []string{},
+				Cover:       "p1.jpg",
+				FileTitle:   true,
+			},
+		},
+		{
+			name:    "invalid comic info",
+			file:    "Watchmen 01.cbz",
+			entries: []zipEntry{{"ComicInfo.xml", "<ComicInfo><Title>Broken"}},
+			want: Metadata{
+				Title:       "Watchmen 01",
+				Authors:     []string{},
+				Series:      "Watchmen",
+				SeriesIndex: 1,
+				Tags:        []string{},
+				FileTitle:   true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, tt.file, buildZip(t, tt.entries...))
+			got, err := parseMetadataFromComic(path, "cbz")
+			if err != nil {
+				t.Fatalf("parseMetadataFromComic() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetadataFromComic() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComicType(t *testing.T) {
+	images := buildZip(t, zipEntry{"001.jpg", "page"}, zipEntry{"ComicInfo.xml", "<ComicInfo/>"}, zipEntry{".DS_Store", ""})
+	document := buildZip(t, zipEntry{"001.jpg", "page"}, zipEntry{"notes.txt", "text"})
+	rar := append([]byte("Rar!\x1a\x07\x01\x00"), make([]byte, 32)...)
+	sevenZip := append([]byte("7z\xbc\xaf\x27\x1c\x00\x04"), make([]byte, 32)...)
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want string
+	}{
+		{name: "cbz", file: "a.cbz", data: document, want: "cbz"},
+		{name: "zip of images", file: "a.zip", data: images, want: "cbz"},
+		{name: "zip of documents", file: "a.zip", data: document},
+		{name: "zip named cbr", file: "a.cbr", data: document, want: "cbz"},
+		{name: "cbr", file: "a.CBR", data: rar, want: "cbr"},
+		{name: "rar", file: "a.rar", data: rar},
+		{name: "rar named cb7", file: "a.cb7", data: rar, want: "cbr"},
+		{name: "cb7", file: "a.cb7", data: sevenZip, want: "cb7"},
+		{name: "7z", file: "a.7z", data: sevenZip},
+		{name: "7z named cbz", file: "a.cbz", data: sevenZip, want: "cb7"},
+		{name: "other content", file: "a.cbr", data: []byte("not an archive")},
+		{name: "short", file: "a.cbr", data: []byte("Rar!")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := comicType(bytes.NewReader(tt.data), int64(len(tt.data)), tt.file); got != tt.want {
+				t.Errorf("comicType(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
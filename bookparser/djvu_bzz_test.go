@@ -0,0 +1,69 @@
+package bookparser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// testdata/antz.bzz is the ANTz chunk of a document made by pdf2djvu,
+// testdata/antz.txt its annotations, the metadata and XMP pdf2djvu copies
+// from the PDF.
+
+func TestDecodeBzz(t *testing.T) {
+	data, err := os.ReadFile("testdata/antz.bzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/antz.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeBzz(bytes.NewReader(data), djvuMaxAnnotSize)
+	if err != nil {
+		t.Fatalf("decodeBzz() error = %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decodeBzz() = %q, want %q", got, want)
+	}
+
+	if _, err := decodeBzz(bytes.NewReader(data), len(want)-1); !errors.Is(err, errCorruptBzz) {
+		t.Errorf("decodeBzz() past limit error = %v, want %v", err, errCorruptBzz)
+	}
+
+	for _, n := range []int{10, len(data) / 2, len(data) - 1} {
+		if _, err := decodeBzz(bytes.NewReader(data[:n]), djvuMaxAnnotSize); !errors.Is(err, errCorruptBzz) {
+			t.Errorf("decodeBzz() of %d bytes error = %v, want %v", n, err, errCorruptBzz)
+		}
+	}
+}
+
+func TestUnbwt(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		markerpos int
+		want      string
+		wantErr   bool
+	}{
+		{name: "single byte", data: "a\x00", markerpos: 1, want: "a"},
+		{name: "no marker", data: "ab\x00c", markerpos: -1, wantErr: true},
+		{name: "marker first", data: "ab\x00c", markerpos: 0, wantErr: true},
+		{name: "marker past the end", data: "ab\x00c", markerpos: 4, wantErr: true},
+		{name: "marker off the cycle", data: "ab\x00c", markerpos: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unbwt([]byte(tt.data), tt.markerpos)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unbwt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("unbwt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package bookparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	djvuMaxDepth      = 4       // FORM nesting of bundled documents is at most 2
+	djvuMaxAnnotSize  = 1 << 20 // annotation chunks larger than this are skipped
+	djvuMaxAnnotDepth = 32      // lists of annotations nest a few levels deep
+)
+
+var errInvalidDjvu = errors.New("invalid djvu chunk")
+
+// isDjvu check is mime type djvu, a single page (DJVU) or multi-page (DJVM) document.
+func isDjvu(f io.ReaderAt, size int64) bool {
+	if size < 16 {
+		return false
+	}
+	buf := make([]byte, 16)
+	f.ReadAt(buf, 0)
+	if !bytes.Equal(buf[:8], []byte("AT&TFORM")) {
+		return false
+	}
+	formType := string(buf[12:16])
+	return formType == "DJVU" || formType == "DJVM"
+}
+
+// walkDjvu calls fn with the id and the content of every chunk of an IFF
+// chunk list between start and end, entering FORM chunks.
+func walkDjvu(f io.ReaderAt, start, end int64, depth int, fn func(id string, r *io.SectionReader) error) error {
+	header := make([]byte, 8)
+	for pos := start; pos+8 <= end; {
+		if _, err := f.ReadAt(header, pos); err != nil {
+			return err
+		}
+		id := string(header[:4])
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if pos+8+size > end {
+			return errInvalidDjvu
+		}
+
+		if id == "FORM" {
+			if depth >= djvuMaxDepth || size < 4 {
+				return errInvalidDjvu
+			}
+			if err := walkDjvu(f, pos+12, pos+8+size, depth+1, fn); err != nil {
+				return err
+			}
+		} else if err := fn(id, io.NewSectionReader(f, pos+8, size)); err != nil {
+			return err
+		}
+
+		// Chunks are aligned on even offsets
+		pos += 8 + size + size&1
+	}
+	return nil
+}
+
+// sexpr is a value of a DjVu annotation, a list, a symbol or a string.
+type sexpr struct {
+	list   []sexpr
+	atom   string
+	isList bool
+}
+
+// parseAnnotations parses the S-expressions of a DjVu annotation chunk, such
+// as `(metadata (title "Foo") (author "Bar"))`. Parsing stops at the first
+// syntax error or at lists nested deeper than djvuMaxAnnotDepth, returning
+// the expressions read so far.
+func parseAnnotations(data []byte) []sexpr {
+	p := annotParser{data: data}
+	var exprs []sexpr
+	for {
+		expr, ok := p.next()
+		if !ok {
+			return exprs
+		}
+		exprs = append(exprs, expr)
+	}
+}
+
+type annotParser struct {
+	data  []byte
+	pos   int
+	depth int // of lists being read
+}
+
+func (p *annotParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ';': // comment until the end of the line
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == 0:
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next expression, ok is false at the end of the data,
+// the end of a list, on a syntax error or on a list nested too deep.
+func (p *annotParser) next() (expr sexpr, ok bool) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return sexpr{}, false
+	}
+
+	switch p.data[p.pos] {
+	case ')':
+		return sexpr{}, false
+	case '(':
+		if p.depth >= djvuMaxAnnotDepth {
+			return sexpr{}, false
+		}
+		p.pos++
+		p.depth++
+		expr.isList = true
+		for {
+			item, ok := p.next()
+			if !ok {
+				break
+			}
+			expr.list = append(expr.list, item)
+		}
+		p.depth--
+		if p.pos >= len(p.data) || p.data[p.pos] != ')' {
+			return sexpr{}, false
+		}
+		p.pos++
+		return expr, true
+	case '"':
+		s, ok := p.str()
+		return sexpr{atom: s}, ok
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && !strings.ContainsRune(" \t\r\n()\";", rune(p.data[p.pos])) {
+			p.pos++
+		}
+		return sexpr{atom: string(p.data[start:p.pos])}, true
+	}
+}
+
+// str reads a quoted string with C escapes, which are octal for bytes of
+// UTF-8 text.
+func (p *annotParser) str() (string, bool) {
+	var sb []byte
+	for p.pos++; p.pos < len(p.data); p.pos++ {
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return string(sb), true
+		case c == '\\' && p.pos+1 < len(p.data):
+			p.pos++
+			switch e := p.data[p.pos]; e {
+			case 'n':
+				sb = append(sb, '\n')
+			case 't':
+				sb = append(sb, '\t')
+			case 'r':
+				sb = append(sb, '\r')
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				end := p.pos
+				for end < len(p.data) && end < p.pos+3 && p.data[end] >= '0' && p.data[end] <= '7' {
+					end++
+				}
+				n, _ := strconv.ParseUint(string(p.data[p.pos:end]), 8, 8)
+				sb = append(sb, byte(n))
+				p.pos = end - 1
+			default:
+				sb = append(sb, e)
+			}
+		default:
+			sb = append(sb, c)
+		}
+	}
+	return "", false
+}
+
+// djvuMetadata returns the key value pairs of the metadata annotations in
+// exprs. The first value of a key wins.
+func djvuMetadata(exprs []sexpr, metadata map[string]string) {
+	for _, expr := range exprs {
+		if !expr.isList || len(expr.list) == 0 || expr.list[0].atom != "metadata" {
+			continue
+		}
+		for _, pair := range expr.list[1:] {
+			if !pair.isList || len(pair.list) < 2 || pair.list[0].isList || pair.list[1].isList {
+				continue
+			}
+			key := strings.ToLower(pair.list[0].atom)
+			value := strings.TrimSpace(pair.list[1].atom)
+			if _, found := metadata[key]; !found && value != "" {
+				metadata[key] = value
+			}
+		}
+	}
+}
+
+// parseMetadataFromDjvu reads the metadata annotations of a DjVu document,
+// fallback to the file name. Annotations are read from ANTa chunks and BZZ
+// compressed ANTz chunks, of the pages and of the shared annotations of
+// bundled documents.
+func parseMetadataFromDjvu(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+
+	values := make(map[string]string)
+	// Skip the "AT&T" magic, a broken chunk ends the walk but keeps the
+	// metadata read so far
+	walkDjvu(f, 4, fi.Size(), 0, func(id string, r *io.SectionReader) error {
+		if (id != "ANTa" && id != "ANTz") || r.Size() > djvuMaxAnnotSize {
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if id == "ANTz" {
+			// A corrupt annotation chunk is skipped
+			if data, err = decodeBzz(bytes.NewReader(data), djvuMaxAnnotSize); err != nil {
+				return nil
+			}
+		}
+		djvuMetadata(parseAnnotations(data), values)
+		return nil
+	})
+
+	title := values["title"]
+	fileTitle := title == ""
+	if fileTitle {
+		title = getTitleFromFilePath(path)
+	}
+
+	authors := []string{}
+	for _, author := range strings.Split(values["author"], ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+
+	tags := []string{}
+	for _, tag := range strings.FieldsFunc(values["keywords"], func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return Metadata{
+		ISBN:        values["isbn"],
+		Title:       title,
+		Authors:     authors,
+		Publisher:   values["publisher"],
+		Language:    values["language"],
+		Description: values["note"],
+		PublishDate: normalizeDate(values["year"]),
+		Tags:        tags,
+		FileTitle:   fileTitle,
+	}, nil
+}
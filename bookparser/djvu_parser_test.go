@@ -0,0 +1,48 @@
+package bookparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	deep := strings.Repeat("(", 1<<16) + strings.Repeat(")", 1<<16)
+
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			name: "metadata",
+			data: "(metadata\n\t(Title \"Dune\") ; comment\n\t(author \"Frank \\110erbert\")\n\t(title \"Other\"))\n(xmp \"<x/>\")",
+			want: map[string]string{"title": "Dune", "author": "Frank Herbert"},
+		},
+		{
+			name: "stops at a syntax error",
+			data: `(metadata (title "Dune")) (metadata (author "unterminated`,
+			want: map[string]string{"title": "Dune"},
+		},
+		{
+			name: "stops at lists nested too deep",
+			data: `(metadata (title "Dune")) ` + deep + ` (metadata (author "Herbert"))`,
+			want: map[string]string{"title": "Dune"},
+		},
+		{
+			name: "nested lists within the limit",
+			data: `(maparea "" "" (rect 1 2 3 4) (border (xor))) (metadata (title "Dune"))`,
+			want: map[string]string{"title": "Dune"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			djvuMetadata(parseAnnotations([]byte(tt.data)), got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("metadata = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
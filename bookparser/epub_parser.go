@@ -5,7 +5,7 @@ import "github.com/pirmd/epub"
 func parseMetadataFromEpub(path string) (Metadata, error) {
 	metadata, err := epub.GetMetadataFromFile(path)
 	if err != nil {
-		return Metadata{Title: getTitleFromFilePath(path)}, nil
+		return fileNameMetadata(path), nil
 	}
 	isbn := ""
 	if len(metadata.Source) > 0 {
@@ -15,7 +15,8 @@ func parseMetadataFromEpub(path string) (Metadata, error) {
 	if len(metadata.Title) > 0 {
 		title = metadata.Title[0]
 	}
-	if title == "" {
+	fileTitle := title == ""
+	if fileTitle {
 		title = getTitleFromFilePath(path)
 	}
 
@@ -33,6 +34,21 @@ func parseMetadataFromEpub(path string) (Metadata, error) {
 	if len(metadata.Publisher) > 0 {
 		publisher = metadata.Publisher[0]
 	}
+	language := ""
+	if len(metadata.Language) > 0 {
+		language = metadata.Language[0]
+	}
+	description := ""
+	if len(metadata.Description) > 0 {
+		description = metadata.Description[0]
+	}
+	publishDate := ""
+	for _, date := range metadata.Date {
+		// Prefer the publication event, fallback to the first date
+		if date.Event == "publication" || publishDate == "" {
+			publishDate = normalizeDate(date.Stamp)
+		}
+	}
 	tags := []string{}
 	for _, tag := range metadata.Subject {
 		if tag != "" {
@@ -41,10 +57,16 @@ func parseMetadataFromEpub(path string) (Metadata, error) {
 	}
 
 	return Metadata{
-		ISBN:      isbn,
-		Title:     title,
-		Authors:   authors,
-		Publisher: publisher,
-		Tags:      tags,
+		ISBN:        isbn,
+		Title:       title,
+		Authors:     authors,
+		Publisher:   publisher,
+		Language:    language,
+		Description: description,
+		PublishDate: publishDate,
+		Series:      metadata.Series,
+		SeriesIndex: parseSeriesIndex(metadata.SeriesIndex),
+		Tags:        tags,
+		FileTitle:   fileTitle,
 	}, nil
 }
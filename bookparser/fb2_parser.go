@@ -0,0 +1,296 @@
+package bookparser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// fb2SniffSize is how much of a file is read to find the root element.
+const fb2SniffSize = 64 << 10
+
+type fb2Description struct {
+	TitleInfo struct {
+		Genre      []string      `xml:"genre"`
+		Author     []fb2Author   `xml:"author"`
+		BookTitle  string        `xml:"book-title"`
+		Annotation fb2Text       `xml:"annotation"`
+		Date       fb2Date       `xml:"date"`
+		Lang       string        `xml:"lang"`
+		Sequence   []fb2Sequence `xml:"sequence"`
+	} `xml:"title-info"`
+	PublishInfo struct {
+		Publisher string        `xml:"publisher"`
+		Year      string        `xml:"year"`
+		ISBN      string        `xml:"isbn"`
+		Sequence  []fb2Sequence `xml:"sequence"`
+	} `xml:"publish-info"`
+}
+
+type fb2Author struct {
+	FirstName  string `xml:"first-name"`
+	MiddleName string `xml:"middle-name"`
+	LastName   string `xml:"last-name"`
+	Nickname   string `xml:"nickname"`
+}
+
+type fb2Date struct {
+	Value string `xml:"value,attr"`
+	Text  string `xml:",chardata"`
+}
+
+type fb2Sequence struct {
+	Name   string `xml:"name,attr"`
+	Number string `xml:"number,attr"`
+}
+
+// fb2Text is the text of an element with formatting, such as an annotation
+// made of paragraphs. Paragraphs are separated by a newline.
+type fb2Text string
+
+func (t *fb2Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var sb strings.Builder
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+			switch tok.Name.Local {
+			case "p", "v", "subtitle", "empty-line":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			sb.Write(tok)
+		}
+	}
+
+	lines := strings.Split(sb.String(), "\n")
+	paragraphs := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	*t = fb2Text(strings.Join(paragraphs, "\n"))
+
+	return nil
+}
+
+// name returns the full name of an author, or the nickname if there is no name.
+func (a fb2Author) name() string {
+	parts := []string{}
+	for _, part := range []string{a.FirstName, a.MiddleName, a.LastName} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return strings.TrimSpace(a.Nickname)
+	}
+	return strings.Join(parts, " ")
+}
+
+// newFB2Decoder returns a decoder of FictionBook XML, which is often not
+// encoded in UTF-8 but e.g. in windows-1251.
+func newFB2Decoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported encoding %s", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return decoder
+}
+
+// isFictionBook check is the root element of the XML in r FictionBook.
+func isFictionBook(r io.Reader) bool {
+	br := bufio.NewReader(io.LimitReader(r, fb2SniffSize))
+	// Skip the byte order mark and whitespace, XML starts with '<'
+	head, _ := br.Peek(64)
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(head) == 0 || head[0] != '<' {
+		return false
+	}
+
+	decoder := newFB2Decoder(br)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local == "FictionBook"
+		}
+	}
+}
+
+// fb2Entry returns the FictionBook file of a fb2.zip archive, nil if there is none.
+func fb2Entry(r *zip.Reader) *zip.File {
+	for _, file := range r.File {
+		if !strings.EqualFold(path.Ext(file.Name), ".fb2") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			continue
+		}
+		ok := isFictionBook(rc)
+		rc.Close()
+		if ok {
+			return file
+		}
+	}
+	return nil
+}
+
+// isFB2 check is mime type fb2, either FictionBook XML or a zip archive with
+// a FictionBook file.
+func isFB2(f io.ReaderAt, size int64) bool {
+	if isZip(f, size) {
+		r, err := zip.NewReader(f, size)
+		if err != nil {
+			return false
+		}
+		return fb2Entry(r) != nil
+	}
+	return isFictionBook(io.NewSectionReader(f, 0, size))
+}
+
+// readFB2Description decodes the description of a FictionBook, without
+// reading the body.
+func readFB2Description(r io.Reader) (fb2Description, error) {
+	var desc fb2Description
+	decoder := newFB2Decoder(r)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return desc, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "description" {
+			err := decoder.DecodeElement(&desc, &se)
+			return desc, err
+		}
+	}
+}
+
+// openFB2 opens the FictionBook XML of a fb2 or fb2.zip file.
+func openFB2(filePath string) (io.ReadCloser, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !isZip(f, fi.Size()) {
+		return f, nil
+	}
+	defer f.Close()
+
+	r, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	entry := fb2Entry(r)
+	if entry == nil {
+		return nil, fmt.Errorf("no fb2 file in %s", filePath)
+	}
+	data, err := func() ([]byte, error) {
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func parseMetadataFromFB2(path string) (Metadata, error) {
+	r, err := openFB2(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer r.Close()
+
+	desc, err := readFB2Description(r)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	titleInfo, publishInfo := desc.TitleInfo, desc.PublishInfo
+
+	title := strings.TrimSpace(titleInfo.BookTitle)
+	fileTitle := title == ""
+	if fileTitle {
+		title = getTitleFromFilePath(path)
+	}
+
+	authors := []string{}
+	for _, author := range titleInfo.Author {
+		name := author.name()
+		if name == "" {
+			name = "Unknown"
+		}
+		authors = append(authors, name)
+	}
+
+	tags := []string{}
+	for _, genre := range titleInfo.Genre {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			tags = append(tags, genre)
+		}
+	}
+
+	// The sequence of the book, fallback to the sequence of the publication
+	series, seriesIndex := "", 0.0
+	for _, seq := range append(titleInfo.Sequence, publishInfo.Sequence...) {
+		if name := strings.TrimSpace(seq.Name); name != "" {
+			series, seriesIndex = name, parseSeriesIndex(seq.Number)
+			break
+		}
+	}
+
+	// Prefer the year of publication, fallback to the date of writing
+	publishDate := normalizeDate(publishInfo.Year)
+	if publishDate == "" {
+		publishDate = normalizeDate(titleInfo.Date.Value)
+	}
+	if publishDate == "" {
+		publishDate = normalizeDate(titleInfo.Date.Text)
+	}
+
+	return Metadata{
+		ISBN:        strings.TrimSpace(publishInfo.ISBN),
+		Title:       title,
+		Authors:     authors,
+		Publisher:   strings.TrimSpace(publishInfo.Publisher),
+		Language:    strings.TrimSpace(titleInfo.Lang),
+		Description: string(titleInfo.Annotation),
+		PublishDate: publishDate,
+		Series:      series,
+		SeriesIndex: seriesIndex,
+		Tags:        tags,
+		FileTitle:   fileTitle,
+	}, nil
+}
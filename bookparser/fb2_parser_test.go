@@ -0,0 +1,174 @@
+package bookparser
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// zipEntry is a file of a zip archive fixture.
+type zipEntry struct {
+	name    string
+	content string
+}
+
+// buildZip returns a zip archive of entries, in their order.
+func buildZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeFixture writes data to a file name in a temporary directory and
+// returns its path, for parsers that open files.
+func writeFixture(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func fb2Fixture(encoding string, description string) string {
+	return `<?xml version="1.0" encoding="` + encoding + `"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+<description>` + description + `</description>
+<body><section><p>Text</p></section></body>
+</FictionBook>`
+}
+
+func TestParseMetadataFromFB2(t *testing.T) {
+	const warAndPeace = `<title-info>
+	<genre>prose_rus_classic</genre>
+	<author><first-name>Лев</first-name><middle-name>Николаевич</middle-name><last-name>Толстой</last-name></author>
+	<book-title>Война и мир</book-title>
+	<annotation><p>Роман-эпопея.</p><p>Том  первый.</p></annotation>
+	<date value="1869-01-01">1869</date>
+	<lang>ru</lang>
+	<sequence name="Война и мир" number="1"/>
+</title-info>`
+	want := Metadata{
+		Title:       "Война и мир",
+		Authors:     []string{"Лев Николаевич Толстой"},
+		Language:    "ru",
+		Description: "Роман-эпопея.\nТом первый.",
+		PublishDate: "1869-01-01",
+		Series:      "Война и мир",
+		SeriesIndex: 1,
+		Tags:        []string{"prose_rus_classic"},
+	}
+
+	cp1251, err := charmap.Windows1251.NewEncoder().String(fb2Fixture("windows-1251", warAndPeace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	koi8r, err := charmap.KOI8R.NewEncoder().String(fb2Fixture("KOI8-R", warAndPeace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want Metadata
+	}{
+		{name: "utf-8", file: "war.fb2", data: []byte(fb2Fixture("UTF-8", warAndPeace)), want: want},
+		{name: "utf-8 with byte order mark", file: "war.fb2", data: []byte("\xef\xbb\xbf" + fb2Fixture("utf-8", warAndPeace)), want: want},
+		{name: "windows-1251", file: "war.fb2", data: []byte(cp1251), want: want},
+		{name: "koi8-r", file: "war.fb2", data: []byte(koi8r), want: want},
+		{
+			name: "fb2.zip",
+			file: "war.fb2.zip",
+			data: buildZip(t, zipEntry{"cover.jpg", "image"}, zipEntry{"war.fb2", cp1251}),
+			want: want,
+		},
+		{
+			name: "publish info",
+			file: "dune.fb2",
+			data: []byte(fb2Fixture("UTF-8", `<title-info>
+	<author><nickname>ghost</nickname></author>
+	<author><first-name>Frank</first-name><last-name>Herbert</last-name></author>
+	<book-title> Dune </book-title>
+	<date>1965</date>
+</title-info>
+<publish-info>
+	<publisher>Ace</publisher><year>1990</year><isbn>978-0-441-17271-9</isbn>
+	<sequence name="Dune" number="1.5"/>
+</publish-info>`)),
+			want: Metadata{
+				ISBN:        "978-0-441-17271-9",
+				Title:       "Dune",
+				Authors:     []string{"ghost", "Frank Herbert"},
+				Publisher:   "Ace",
+				PublishDate: "1990",
+				Series:      "Dune",
+				SeriesIndex: 1.5,
+				Tags:        []string{},
+			},
+		},
+		{
+			name: "unsupported encoding",
+			file: "war.fb2",
+			data: []byte(fb2Fixture("x-unknown", warAndPeace)),
+			want: Metadata{Title: "war", FileTitle: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, tt.file, tt.data)
+			got, err := parseMetadataFromFB2(path)
+			if err != nil {
+				t.Fatalf("parseMetadataFromFB2() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetadataFromFB2() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFB2(t *testing.T) {
+	book := fb2Fixture("UTF-8", "")
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "xml", data: []byte(book), want: true},
+		{name: "leading white space", data: []byte("\r\n  " + book), want: true},
+		{name: "other xml", data: []byte(`<?xml version="1.0"?><html><body/></html>`)},
+		{name: "text", data: []byte("FictionBook")},
+		{name: "zip", data: buildZip(t, zipEntry{"readme.txt", "text"}, zipEntry{"book.FB2", book}), want: true},
+		{name: "zip without fb2 extension", data: buildZip(t, zipEntry{"book.xml", book})},
+		{name: "zip with other xml", data: buildZip(t, zipEntry{"book.fb2", "<html/>"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFB2(bytes.NewReader(tt.data), int64(len(tt.data))); got != tt.want {
+				t.Errorf("isFB2() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
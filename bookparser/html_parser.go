@@ -0,0 +1,126 @@
+package bookparser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// htmlHeadSize is how much of an HTML file is read to find the head.
+const htmlHeadSize = 1 << 20
+
+// isHTML check is mime type html, a document starting with an html doctype,
+// or any markup in a file named .html or .htm.
+func isHTML(f io.ReaderAt, path string) bool {
+	buf := make([]byte, 512)
+	n, _ := f.ReadAt(buf, 0)
+	head := bytes.TrimLeft(bytes.TrimPrefix(buf[:n], []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(bytes.ToLower(head), []byte("<!doctype html")) {
+		return true
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return len(head) > 0 && head[0] == '<'
+	}
+	return false
+}
+
+// htmlHead returns the title, the lang attribute and the named meta
+// elements of the head of an HTML document, with lower case names. The
+// first value of a meta name is kept, except for citation_author.
+func htmlHead(r io.Reader) (title string, lang string, meta map[string][]string) {
+	meta = make(map[string][]string)
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return title, lang, meta
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := z.Token()
+			attrs := make(map[string]string)
+			for _, attr := range tag.Attr {
+				attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+			}
+			switch tag.Data {
+			case "html":
+				lang = attrs["lang"]
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				name := strings.ToLower(attrs["name"])
+				if name != "" && attrs["content"] != "" && (len(meta[name]) == 0 || name == "citation_author") {
+					meta[name] = append(meta[name], attrs["content"])
+				}
+			case "body":
+				return title, lang, meta
+			}
+		case html.EndTagToken:
+			switch z.Token().Data {
+			case "title":
+				inTitle = false
+			case "head":
+				return title, lang, meta
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		}
+	}
+}
+
+// firstMeta returns the first value of the first meta name that is set.
+func firstMeta(meta map[string][]string, names ...string) string {
+	for _, name := range names {
+		if value := first(meta[name]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseMetadataFromHTML reads the title and the meta elements of an HTML
+// document, including Dublin Core and citation meta names of papers,
+// fallback to the file name.
+func parseMetadataFromHTML(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer f.Close()
+
+	r, err := charset.NewReader(io.LimitReader(f, htmlHeadSize), "")
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	title, lang, meta := htmlHead(r)
+
+	if t := firstMeta(meta, "dc.title", "citation_title"); t != "" {
+		title = t
+	}
+	creator := strings.Join(meta["citation_author"], ";")
+	if creator == "" {
+		creator = firstMeta(meta, "author", "dc.creator")
+	}
+	if lang == "" {
+		lang = firstMeta(meta, "dc.language")
+	}
+
+	return documentMetadata(
+		path,
+		strings.Join(strings.Fields(title), " "),
+		creator,
+		firstMeta(meta, "description", "dc.description"),
+		lang,
+		// citation dates are written like 2006/01/02
+		strings.ReplaceAll(firstMeta(meta, "citation_publication_date", "citation_date", "dc.date"), "/", "-"),
+		splitKeywords(firstMeta(meta, "keywords", "dc.subject")),
+	), nil
+}
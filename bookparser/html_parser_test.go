@@ -0,0 +1,153 @@
+package bookparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestParseMetadataFromHTML(t *testing.T) {
+	latin1, err := charmap.ISO8859_1.NewEncoder().String(`<html lang="fr"><head>
+<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1">
+<title>L'Étranger</title></head><body></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		html string
+		want Metadata
+	}{
+		{
+			name: "citation",
+			file: "paper.html",
+			html: `<!DOCTYPE html>
+<html lang="en"><head>
+<title>Site | Paper</title>
+<meta name="citation_title" content="Attention Is All You Need">
+<meta name="citation_author" content="Vaswani, Ashish">
+<meta name="citation_author" content="Shazeer, Noam">
+<meta name="CITATION_AUTHOR" content=" Parmar, Niki ">
+<meta name="citation_publication_date" content="2017/06/12">
+<meta name="citation_date" content="2018/01/01">
+<meta name="description" content="The dominant sequence transduction models...">
+<meta name="keywords" content="transformer, attention; translation">
+<meta name="author" content="Ignored">
+</head><body><meta name="citation_author" content="In Body"></body></html>`,
+			want: Metadata{
+				Title:       "Attention Is All You Need",
+				Authors:     []string{"Vaswani, Ashish", "Shazeer, Noam", "Parmar, Niki"},
+				Language:    "en",
+				Description: "The dominant sequence transduction models...",
+				PublishDate: "2017-06-12",
+				Tags:        []string{"transformer", "attention", "translation"},
+			},
+		},
+		{
+			name: "citation date without publication date",
+			file: "paper.html",
+			html: `<html><head><title>Paper</title><meta name="citation_date" content="2018/03"></head></html>`,
+			want: Metadata{
+				Title:       "Paper",
+				Authors:     []string{},
+				PublishDate: "2018-03",
+				Tags:        []string{},
+			},
+		},
+		{
+			name: "dublin core",
+			file: "page.htm",
+			html: `<html><head>
+<title>Title</title>
+<meta name="DC.title" content="Dublin Core Title">
+<meta name="DC.creator" content="First; Second">
+<meta name="DC.language" content="de">
+<meta name="DC.date" content="2001-02-03">
+<meta name="DC.subject" content="one, two">
+<meta name="DC.description" content="Text">
+</head></html>`,
+			want: Metadata{
+				Title:       "Dublin Core Title",
+				Authors:     []string{"First", "Second"},
+				Language:    "de",
+				Description: "Text",
+				PublishDate: "2001-02-03",
+				Tags:        []string{"one", "two"},
+			},
+		},
+		{
+			name: "title and author",
+			file: "page.html",
+			html: "<html><head><title>\n  A   Long\n Title </title><title>Second</title><meta name=\"author\" content=\"Author\"></head></html>",
+			want: Metadata{
+				Title:   "A Long Title",
+				Authors: []string{"Author"},
+				Tags:    []string{},
+			},
+		},
+		{
+			name: "charset",
+			file: "etranger.html",
+			html: latin1,
+			want: Metadata{
+				Title:    "L'Étranger",
+				Authors:  []string{},
+				Language: "fr",
+				Tags:     []string{},
+			},
+		},
+		{
+			name: "no title",
+			file: "fragment.html",
+			html: `<p>Text</p>`,
+			want: Metadata{
+				Title:     "fragment",
+				Authors:   []string{},
+				Tags:      []string{},
+				FileTitle: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, tt.file, []byte(tt.html))
+			got, err := parseMetadataFromHTML(path)
+			if err != nil {
+				t.Fatalf("parseMetadataFromHTML() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetadataFromHTML() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want bool
+	}{
+		{name: "doctype", file: "page.xhtml", data: "\xef\xbb\xbf\n<!DOCTYPE HTML>", want: true},
+		{name: "doctype without extension", file: "page", data: "<!doctype html><html>", want: true},
+		{name: "markup in html file", file: "page.HTML", data: "  <p>Text", want: true},
+		{name: "markup in htm file", file: "page.htm", data: "<html>", want: true},
+		{name: "text in html file", file: "page.html", data: "Text <b>bold</b>"},
+		{name: "markup in other file", file: "page.xml", data: "<html>"},
+		{name: "empty", file: "page.html", data: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isHTML(bytes.NewReader([]byte(tt.data)), tt.file); got != tt.want {
+				t.Errorf("isHTML(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
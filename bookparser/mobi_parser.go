@@ -1,40 +1,260 @@
 package bookparser
 
 import (
-	mobipocket "github.com/clee/gobipocket"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EXTH record types of MOBI metadata.
+const (
+	exthAuthor      = 100
+	exthPublisher   = 101
+	exthDescription = 103
+	exthISBN        = 104
+	exthSubject     = 105
+	exthPublishDate = 106
+	exthASIN        = 113
+	exthCoverOffset = 201
+	exthTitle       = 503
+	exthLanguage    = 524
+)
+
+const (
+	palmHeaderSize  = 78
+	mobiKF8Version  = 8
+	mobiCP1252      = 1252
+	mobiNoImage     = 0xFFFFFFFF
+	mobiHeaderStart = 16 // the MOBI header follows the PalmDOC header in record 0
 )
 
+var errInvalidMobi = errors.New("invalid mobi header")
+
+// mobiBook is the header of a MOBI or KF8 file, read from its first record.
+type mobiBook struct {
+	records    []uint32 // offsets of the PalmDB records
+	version    int      // MOBI file version, 8 for KF8
+	title      string
+	firstImage uint32 // record of the first image
+	exth       map[uint32][][]byte
+}
+
+// readMobi reads the PalmDB record list, the MOBI header and the EXTH
+// records of a MOBI file.
+func readMobi(f io.ReaderAt, size int64) (mobiBook, error) {
+	var book mobiBook
+
+	header := make([]byte, palmHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return book, err
+	}
+	count := int(binary.BigEndian.Uint16(header[76:78]))
+	if count == 0 {
+		return book, errInvalidMobi
+	}
+	list := make([]byte, count*8)
+	if _, err := f.ReadAt(list, palmHeaderSize); err != nil {
+		return book, err
+	}
+	book.records = make([]uint32, count)
+	for i := range book.records {
+		book.records[i] = binary.BigEndian.Uint32(list[i*8:])
+	}
+
+	record0, err := readRecord(f, size, book.records, 0)
+	if err != nil {
+		return book, err
+	}
+	if len(record0) < mobiHeaderStart+0x74 || string(record0[mobiHeaderStart:mobiHeaderStart+4]) != "MOBI" {
+		return book, errInvalidMobi
+	}
+	u32 := func(offset int) uint32 {
+		return binary.BigEndian.Uint32(record0[offset:])
+	}
+	headerLength := int(u32(0x14))
+	encoding := u32(0x1C)
+	book.version = int(u32(0x24))
+	book.firstImage = u32(0x6C)
+
+	decode := func(b []byte) string {
+		if encoding == mobiCP1252 {
+			s, err := charmap.Windows1252.NewDecoder().Bytes(b)
+			if err == nil {
+				b = s
+			}
+		}
+		return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+	}
+
+	nameOffset, nameLength := int(u32(0x54)), int(u32(0x58))
+	if nameOffset > 0 && nameOffset+nameLength <= len(record0) {
+		book.title = decode(record0[nameOffset : nameOffset+nameLength])
+	}
+
+	book.exth = make(map[uint32][][]byte)
+	exthStart := mobiHeaderStart + headerLength
+	if u32(0x80)&0x40 == 0 || exthStart+12 > len(record0) || string(record0[exthStart:exthStart+4]) != "EXTH" {
+		return book, nil
+	}
+	exthCount := int(u32(exthStart + 8))
+	pos := exthStart + 12
+	for i := 0; i < exthCount && pos+8 <= len(record0); i++ {
+		recordType, recordLength := u32(pos), int(u32(pos+4))
+		if recordLength < 8 || pos+recordLength > len(record0) {
+			break
+		}
+		value := record0[pos+8 : pos+recordLength]
+		if recordType != exthCoverOffset {
+			value = []byte(decode(value))
+		}
+		book.exth[recordType] = append(book.exth[recordType], value)
+		pos += recordLength
+	}
+
+	return book, nil
+}
+
+// readRecord returns the PalmDB record i.
+func readRecord(f io.ReaderAt, size int64, records []uint32, i int) ([]byte, error) {
+	if i < 0 || i >= len(records) {
+		return nil, fmt.Errorf("no record %d", i)
+	}
+	start, end := int64(records[i]), size
+	if i+1 < len(records) {
+		end = int64(records[i+1])
+	}
+	if start >= end || end > size {
+		return nil, errInvalidMobi
+	}
+	record := make([]byte, end-start)
+	if _, err := f.ReadAt(record, start); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// values returns the text of the EXTH records of a type.
+func (m mobiBook) values(recordType uint32) []string {
+	values := []string{}
+	for _, value := range m.exth[recordType] {
+		if len(value) > 0 {
+			values = append(values, string(value))
+		}
+	}
+	return values
+}
+
+// value returns the text of the first EXTH record of a type.
+func (m mobiBook) value(recordType uint32) string {
+	return first(m.values(recordType))
+}
+
+// cover returns the record of the cover image, ok is false if there is none.
+func (m mobiBook) cover() (record int, ok bool) {
+	offsets := m.exth[exthCoverOffset]
+	if len(offsets) == 0 || len(offsets[0]) != 4 || m.firstImage == mobiNoImage {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(offsets[0])
+	if offset == mobiNoImage {
+		return 0, false
+	}
+	record = int(m.firstImage) + int(offset)
+	if record >= len(m.records) {
+		return 0, false
+	}
+	return record, true
+}
+
+// mobiFileType returns "azw3" for KF8 files, otherwise "mobi". Joint files
+// with a MOBI and a KF8 part, as made by kindlegen, are MOBI files.
+func mobiFileType(f io.ReaderAt, size int64) string {
+	book, err := readMobi(f, size)
+	if err == nil && book.version == mobiKF8Version {
+		return "azw3"
+	}
+	return "mobi"
+}
+
 func parseMetadataFromMobi(path string) (Metadata, error) {
-	m, err := mobipocket.Open(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
 	if err != nil {
-		return Metadata{Title: getTitleFromFilePath(path)}, nil
+		return fileNameMetadata(path), nil
 	}
-	title := ""
-	if len(m.Metadata["title"]) > 0 {
-		title = m.Metadata["title"][0]
+	m, err := readMobi(f, fi.Size())
+	if err != nil {
+		return fileNameMetadata(path), nil
 	}
+
+	title := m.value(exthTitle)
 	if title == "" {
+		title = m.title
+	}
+	fileTitle := title == ""
+	if fileTitle {
 		title = getTitleFromFilePath(path)
 	}
 
-	authors := m.Metadata["author"]
-	for i := range authors {
-		if authors[i] == "" {
-			authors[i] = "Unknown"
+	authors := m.values(exthAuthor)
+
+	tags := []string{}
+	for _, subject := range m.values(exthSubject) {
+		for _, tag := range strings.Split(subject, ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
 		}
 	}
 
-	publisher := ""
-	if len(m.Metadata["publisher"]) > 0 {
-		publisher = m.Metadata["publisher"][0]
+	cover := ""
+	if record, ok := m.cover(); ok {
+		cover = strconv.Itoa(record)
 	}
 
 	return Metadata{
-		ISBN:      "",
-		Title:     title,
-		Authors:   authors,
-		Publisher: publisher,
-		Tags:      []string{},
+		ISBN:        m.value(exthISBN),
+		ASIN:        m.value(exthASIN),
+		Title:       title,
+		Authors:     authors,
+		Publisher:   m.value(exthPublisher),
+		Language:    m.value(exthLanguage),
+		Description: m.value(exthDescription),
+		PublishDate: normalizeDate(m.value(exthPublishDate)),
+		Tags:        tags,
+		Cover:       cover,
+		FileTitle:   fileTitle,
 	}, nil
+}
 
+// readMobiCover returns the PalmDB record of the cover image.
+func readMobiCover(path string, cover string) ([]byte, error) {
+	record, err := strconv.Atoi(cover)
+	if err != nil {
+		return nil, ErrNoCover
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	m, err := readMobi(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	return readRecord(f, fi.Size(), m.records, record)
 }
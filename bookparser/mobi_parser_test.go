@@ -0,0 +1,269 @@
+package bookparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// exthRecord is an EXTH record of a test fixture, length is the declared
+// record length, 0 for the actual one.
+type exthRecord struct {
+	recordType uint32
+	value      []byte
+	length     uint32
+}
+
+// mobiFixture describes a small PalmDB file with a MOBI header.
+type mobiFixture struct {
+	version    uint32
+	title      string
+	firstImage uint32
+	exth       []exthRecord
+	images     [][]byte // records following record 0
+	truncate   int      // length of record 0 if not 0
+}
+
+// build returns the bytes of the PalmDB file.
+func (m mobiFixture) build() []byte {
+	const headerLength = 0xE8
+
+	record0 := make([]byte, mobiHeaderStart+headerLength)
+	put := func(offset int, v uint32) {
+		binary.BigEndian.PutUint32(record0[offset:], v)
+	}
+	copy(record0[mobiHeaderStart:], "MOBI")
+	put(0x14, headerLength)
+	put(0x1C, 65001)
+	put(0x24, m.version)
+	put(0x6C, m.firstImage)
+
+	if len(m.exth) > 0 {
+		put(0x80, 0x40)
+		var exth bytes.Buffer
+		exth.WriteString("EXTH")
+		binary.Write(&exth, binary.BigEndian, uint32(0))
+		binary.Write(&exth, binary.BigEndian, uint32(len(m.exth)))
+		for _, r := range m.exth {
+			length := r.length
+			if length == 0 {
+				length = uint32(8 + len(r.value))
+			}
+			binary.Write(&exth, binary.BigEndian, r.recordType)
+			binary.Write(&exth, binary.BigEndian, length)
+			exth.Write(r.value)
+		}
+		record0 = append(record0, exth.Bytes()...)
+	}
+
+	put(0x54, uint32(len(record0)))
+	put(0x58, uint32(len(m.title)))
+	record0 = append(record0, m.title...)
+
+	if m.truncate > 0 {
+		record0 = record0[:m.truncate]
+	}
+
+	records := append([][]byte{record0}, m.images...)
+	header := make([]byte, palmHeaderSize)
+	copy(header[60:], "BOOKMOBI")
+	binary.BigEndian.PutUint16(header[76:], uint16(len(records)))
+
+	list := make([]byte, len(records)*8)
+	offset := palmHeaderSize + len(list)
+	for i, r := range records {
+		binary.BigEndian.PutUint32(list[i*8:], uint32(offset))
+		offset += len(r)
+	}
+
+	data := append(header, list...)
+	for _, r := range records {
+		data = append(data, r...)
+	}
+	return data
+}
+
+func uint32Bytes(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func TestReadMobi(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   mobiFixture
+		err       error
+		version   int
+		title     string
+		exth      map[uint32][]string
+		cover     int
+		coverOK   bool
+		fileType  string
+		coverData []byte
+	}{
+		{
+			name: "mobi",
+			fixture: mobiFixture{
+				version:    6,
+				title:      "Full Name",
+				firstImage: 1,
+				exth: []exthRecord{
+					{recordType: exthTitle, value: []byte("Title")},
+					{recordType: exthAuthor, value: []byte("First Author")},
+					{recordType: exthAuthor, value: []byte("Second Author")},
+					{recordType: exthASIN, value: []byte("B00B7NPRY8")},
+					{recordType: exthCoverOffset, value: uint32Bytes(1)},
+				},
+				images: [][]byte{[]byte("thumbnail"), []byte("cover")},
+			},
+			version: 6,
+			title:   "Full Name",
+			exth: map[uint32][]string{
+				exthTitle:  {"Title"},
+				exthAuthor: {"First Author", "Second Author"},
+				exthASIN:   {"B00B7NPRY8"},
+			},
+			cover:     2,
+			coverOK:   true,
+			fileType:  "mobi",
+			coverData: []byte("cover"),
+		},
+		{
+			name: "kf8",
+			fixture: mobiFixture{
+				version:    mobiKF8Version,
+				title:      "KF8",
+				firstImage: mobiNoImage,
+			},
+			version:  mobiKF8Version,
+			title:    "KF8",
+			fileType: "azw3",
+		},
+		{
+			name: "truncated record 0",
+			fixture: mobiFixture{
+				version:  6,
+				title:    "Truncated",
+				truncate: mobiHeaderStart + 0x40,
+			},
+			err:      errInvalidMobi,
+			fileType: "mobi",
+		},
+		{
+			name: "exth length past record",
+			fixture: mobiFixture{
+				version:    6,
+				title:      "Long",
+				firstImage: mobiNoImage,
+				exth: []exthRecord{
+					{recordType: exthAuthor, value: []byte("Author")},
+					{recordType: exthPublisher, value: []byte("Publisher"), length: 1 << 20},
+					{recordType: exthLanguage, value: []byte("en")},
+				},
+			},
+			version: 6,
+			title:   "Long",
+			exth: map[uint32][]string{
+				exthAuthor: {"Author"},
+			},
+			fileType: "mobi",
+		},
+		{
+			name: "cover offset past record count",
+			fixture: mobiFixture{
+				version:    6,
+				title:      "No Cover",
+				firstImage: 1,
+				exth: []exthRecord{
+					{recordType: exthCoverOffset, value: uint32Bytes(5)},
+				},
+				images: [][]byte{[]byte("image")},
+			},
+			version:  6,
+			title:    "No Cover",
+			exth:     map[uint32][]string{},
+			fileType: "mobi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.fixture.build()
+			r := bytes.NewReader(data)
+
+			if fileType := mobiFileType(r, int64(len(data))); fileType != tt.fileType {
+				t.Errorf("mobiFileType() = %q, want %q", fileType, tt.fileType)
+			}
+
+			book, err := readMobi(r, int64(len(data)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("readMobi() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if book.version != tt.version {
+				t.Errorf("version = %d, want %d", book.version, tt.version)
+			}
+			if book.title != tt.title {
+				t.Errorf("title = %q, want %q", book.title, tt.title)
+			}
+			for recordType, want := range tt.exth {
+				if got := book.values(recordType); !reflect.DeepEqual(got, want) {
+					t.Errorf("values(%d) = %q, want %q", recordType, got, want)
+				}
+			}
+			if _, found := tt.exth[exthLanguage]; !found && book.value(exthLanguage) != "" {
+				t.Errorf("value(%d) = %q, want none", exthLanguage, book.value(exthLanguage))
+			}
+
+			cover, ok := book.cover()
+			if cover != tt.cover || ok != tt.coverOK {
+				t.Errorf("cover() = %d, %v, want %d, %v", cover, ok, tt.cover, tt.coverOK)
+			}
+			if !ok {
+				return
+			}
+			data, err = readRecord(r, int64(len(data)), book.records, cover)
+			if err != nil {
+				t.Fatalf("readRecord(%d) error = %v", cover, err)
+			}
+			if !bytes.Equal(data, tt.coverData) {
+				t.Errorf("readRecord(%d) = %q, want %q", cover, data, tt.coverData)
+			}
+		})
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	data := []byte("0123456789")
+	tests := []struct {
+		name    string
+		records []uint32
+		i       int
+		want    string
+		wantErr bool
+	}{
+		{name: "first", records: []uint32{0, 4, 8}, i: 0, want: "0123"},
+		{name: "last runs to the end", records: []uint32{0, 4, 8}, i: 2, want: "89"},
+		{name: "negative", records: []uint32{0, 4}, i: -1, wantErr: true},
+		{name: "past record count", records: []uint32{0, 4}, i: 2, wantErr: true},
+		{name: "offsets out of order", records: []uint32{6, 4}, i: 0, wantErr: true},
+		{name: "empty record", records: []uint32{4, 4}, i: 0, wantErr: true},
+		{name: "offset past the file", records: []uint32{0, 20}, i: 0, wantErr: true},
+		{name: "last offset past the file", records: []uint32{0, 20}, i: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRecord(bytes.NewReader(data), int64(len(data)), tt.records, tt.i)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readRecord() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package bookparser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const odtMimeType = "application/vnd.oasis.opendocument.text"
+
+// docxCore is the docProps/core.xml document properties of a DOCX file.
+type docxCore struct {
+	Title       string `xml:"title"`
+	Subject     string `xml:"subject"`
+	Creator     string `xml:"creator"`
+	Keywords    string `xml:"keywords"`
+	Description string `xml:"description"`
+	Language    string `xml:"language"`
+	Created     string `xml:"created"`
+}
+
+// odtMeta is the meta.xml document properties of an ODT file.
+type odtMeta struct {
+	Meta struct {
+		Title          string   `xml:"title"`
+		Subject        string   `xml:"subject"`
+		InitialCreator string   `xml:"initial-creator"`
+		Creator        string   `xml:"creator"`
+		Keyword        []string `xml:"keyword"`
+		Description    string   `xml:"description"`
+		Language       string   `xml:"language"`
+		CreationDate   string   `xml:"creation-date"`
+	} `xml:"meta"`
+}
+
+// officeType returns the file type of an office document, "docx" for OOXML
+// word processing documents and "odt" for ODF text documents, empty if the
+// file is neither.
+func officeType(f io.ReaderAt, size int64) string {
+	if !isZip(f, size) {
+		return ""
+	}
+	r, err := zip.NewReader(f, size)
+	if err != nil {
+		return ""
+	}
+
+	contentTypes, document := false, false
+	for _, file := range r.File {
+		switch file.Name {
+		case "[Content_Types].xml":
+			contentTypes = true
+		case "word/document.xml":
+			document = true
+		case "mimetype":
+			if data, err := readEntry(file, 128); err == nil && strings.TrimSpace(string(data)) == odtMimeType {
+				return "odt"
+			}
+		}
+	}
+	if contentTypes && document {
+		return "docx"
+	}
+	return ""
+}
+
+// readEntry returns at most limit bytes of a zip archive file.
+func readEntry(file *zip.File, limit int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+// decodeZipXML decodes the XML file name of a zip archive into v, ok is
+// false if there is no such file.
+func decodeZipXML(r *zip.Reader, name string, v any) (ok bool, err error) {
+	for _, file := range r.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return false, err
+		}
+		defer rc.Close()
+
+		decoder := xml.NewDecoder(rc)
+		decoder.Entity = xml.HTMLEntity
+		return true, decoder.Decode(v)
+	}
+	return false, nil
+}
+
+// splitKeywords splits keywords separated by commas or semicolons.
+func splitKeywords(keywords ...string) []string {
+	tags := []string{}
+	for _, list := range keywords {
+		for _, tag := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ';' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// documentMetadata returns the metadata of document properties, the title
+// falls back to the file name.
+func documentMetadata(path, title, creator, description, language, date string, tags []string) Metadata {
+	title = strings.TrimSpace(title)
+	fileTitle := title == ""
+	if fileTitle {
+		title = getTitleFromFilePath(path)
+	}
+	authors := []string{}
+	for _, author := range strings.Split(creator, ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+	return Metadata{
+		Title:       title,
+		Authors:     authors,
+		Language:    strings.TrimSpace(language),
+		Description: strings.TrimSpace(description),
+		PublishDate: normalizeDate(date),
+		Tags:        tags,
+		FileTitle:   fileTitle,
+	}
+}
+
+// parseMetadataFromOffice reads the document properties of a DOCX or ODT
+// file, fallback to the file name.
+func parseMetadataFromOffice(path string, fileType string) (Metadata, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer zr.Close()
+
+	switch fileType {
+	case "docx":
+		var core docxCore
+		if ok, err := decodeZipXML(&zr.Reader, "docProps/core.xml", &core); !ok || err != nil {
+			return fileNameMetadata(path), nil
+		}
+		description := core.Description
+		if description == "" {
+			description = core.Subject
+		}
+		return documentMetadata(path, core.Title, core.Creator, description, core.Language, core.Created, splitKeywords(core.Keywords)), nil
+	case "odt":
+		var meta odtMeta
+		if ok, err := decodeZipXML(&zr.Reader, "meta.xml", &meta); !ok || err != nil {
+			return fileNameMetadata(path), nil
+		}
+		m := meta.Meta
+		creator := m.InitialCreator
+		if creator == "" {
+			creator = m.Creator
+		}
+		description := m.Description
+		if description == "" {
+			description = m.Subject
+		}
+		return documentMetadata(path, m.Title, creator, description, m.Language, m.CreationDate, splitKeywords(m.Keyword...)), nil
+	default:
+		return fileNameMetadata(path), nil
+	}
+}
@@ -0,0 +1,251 @@
+package bookparser
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`
+	odtMimeTypeEntry = "application/vnd.oasis.opendocument.text"
+	epubMimeType     = "application/epub+zip"
+	epubContainer    = `<?xml version="1.0"?><container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"/>`
+)
+
+func TestParseMetadataFromOffice(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		fileType string
+		entries  []zipEntry
+		want     Metadata
+	}{
+		{
+			name:     "docx",
+			file:     "report.docx",
+			fileType: "docx",
+			entries: []zipEntry{
+				{"[Content_Types].xml", docxContentTypes},
+				{"word/document.xml", "<w:document/>"},
+				{"docProps/core.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">
+	<dc:title> Annual Report </dc:title>
+	<dc:creator>Ada Lovelace; Charles Babbage</dc:creator>
+	<dc:subject>Engines</dc:subject>
+	<cp:keywords>finance, engines;notes</cp:keywords>
+	<dc:language>en-GB</dc:language>
+	<dcterms:created>1843-09-01T10:00:00Z</dcterms:created>
+</cp:coreProperties>`},
+			},
+			want: Metadata{
+				Title:       "Annual Report",
+				Authors:     []string{"Ada Lovelace", "Charles Babbage"},
+				Language:    "en-GB",
+				Description: "Engines",
+				PublishDate: "1843-09-01",
+				Tags:        []string{"finance", "engines", "notes"},
+			},
+		},
+		{
+			name:     "docx description over subject",
+			file:     "report.docx",
+			fileType: "docx",
+			entries: []zipEntry{
+				{"docProps/core.xml", `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<dc:subject>Subject</dc:subject><dc:description>Description</dc:description>
+</cp:coreProperties>`},
+			},
+			want: Metadata{
+				Title:       "report",
+				Authors:     []string{},
+				Description: "Description",
+				Tags:        []string{},
+				FileTitle:   true,
+			},
+		},
+		{
+			name:     "docx without properties",
+			file:     "notes.docx",
+			fileType: "docx",
+			entries:  []zipEntry{{"[Content_Types].xml", docxContentTypes}, {"word/document.xml", "<w:document/>"}},
+			want:     Metadata{Title: "notes", FileTitle: true},
+		},
+		{
+			name:     "odt",
+			file:     "thesis.odt",
+			fileType: "odt",
+			entries: []zipEntry{
+				{"mimetype", odtMimeTypeEntry},
+				{"meta.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0">
+	<office:meta>
+		<dc:title>Thesis</dc:title>
+		<meta:initial-creator>Marie Curie</meta:initial-creator>
+		<dc:creator>Pierre Curie</dc:creator>
+		<dc:subject>Radioactivity</dc:subject>
+		<meta:keyword>physics</meta:keyword>
+		<meta:keyword>chemistry, radium</meta:keyword>
+		<dc:language>fr</dc:language>
+		<meta:creation-date>1903-06-25T09:00:00</meta:creation-date>
+	</office:meta>
+</office:document-meta>`},
+			},
+			want: Metadata{
+				Title:       "Thesis",
+				Authors:     []string{"Marie Curie"},
+				Language:    "fr",
+				Description: "Radioactivity",
+				PublishDate: "1903-06-25",
+				Tags:        []string{"physics", "chemistry", "radium"},
+			},
+		},
+		{
+			name:     "odt creator without initial creator",
+			file:     "thesis.odt",
+			fileType: "odt",
+			entries: []zipEntry{
+				{"mimetype", odtMimeTypeEntry},
+				{"meta.xml", `<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><office:meta><dc:creator>Pierre Curie</dc:creator></office:meta></office:document-meta>`},
+			},
+			want: Metadata{
+				Title:     "thesis",
+				Authors:   []string{"Pierre Curie"},
+				Tags:      []string{},
+				FileTitle: true,
+			},
+		},
+		{
+			name:     "invalid meta",
+			file:     "broken.odt",
+			fileType: "odt",
+			entries:  []zipEntry{{"mimetype", odtMimeTypeEntry}, {"meta.xml", "<office:document-meta><office:meta>"}},
+			want:     Metadata{Title: "broken", FileTitle: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, tt.file, buildZip(t, tt.entries...))
+			got, err := parseMetadataFromOffice(path, tt.fileType)
+			if err != nil {
+				t.Fatalf("parseMetadataFromOffice() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetadataFromOffice() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfficeType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "docx", data: buildZip(t, zipEntry{"[Content_Types].xml", docxContentTypes}, zipEntry{"word/document.xml", "<w:document/>"}), want: "docx"},
+		{name: "xlsx", data: buildZip(t, zipEntry{"[Content_Types].xml", docxContentTypes}, zipEntry{"xl/workbook.xml", "<workbook/>"})},
+		{name: "document without content types", data: buildZip(t, zipEntry{"word/document.xml", "<w:document/>"})},
+		{name: "odt", data: buildZip(t, zipEntry{"mimetype", odtMimeTypeEntry}, zipEntry{"content.xml", "<office:document-content/>"}), want: "odt"},
+		{name: "odt mimetype with newline", data: buildZip(t, zipEntry{"mimetype", odtMimeTypeEntry + "\n"}), want: "odt"},
+		{name: "ods", data: buildZip(t, zipEntry{"mimetype", "application/vnd.oasis.opendocument.spreadsheet"})},
+		{name: "epub", data: buildZip(t, zipEntry{"mimetype", epubMimeType}, zipEntry{"META-INF/container.xml", epubContainer})},
+		{name: "not a zip", data: []byte("PK")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := officeType(bytes.NewReader(tt.data), int64(len(tt.data))); got != tt.want {
+				t.Errorf("officeType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsEpub(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "mimetype", data: buildZip(t, zipEntry{"mimetype", epubMimeType}), want: true},
+		{name: "container", data: buildZip(t, zipEntry{"META-INF/container.xml", epubContainer}), want: true},
+		{name: "other mimetype", data: buildZip(t, zipEntry{"mimetype", odtMimeTypeEntry})},
+		{name: "docx", data: buildZip(t, zipEntry{"[Content_Types].xml", docxContentTypes}, zipEntry{"word/document.xml", "<w:document/>"})},
+		{name: "zip of images", data: buildZip(t, zipEntry{"001.jpg", "page"})},
+		{name: "not a zip", data: []byte("%PDF-1.7")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEpub(bytes.NewReader(tt.data), int64(len(tt.data))); got != tt.want {
+				t.Errorf("isEpub() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseZipType(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		entries []zipEntry
+		want    string
+	}{
+		{
+			name:    "docx",
+			file:    "report.docx",
+			entries: []zipEntry{{"[Content_Types].xml", docxContentTypes}, {"word/document.xml", "<w:document/>"}},
+			want:    "docx",
+		},
+		{
+			name:    "odt with a container",
+			file:    "thesis.odt",
+			entries: []zipEntry{{"mimetype", odtMimeTypeEntry}, {"META-INF/container.xml", epubContainer}},
+			want:    "odt",
+		},
+		{
+			name:    "epub",
+			file:    "book.epub",
+			entries: []zipEntry{{"mimetype", epubMimeType}, {"META-INF/container.xml", epubContainer}},
+			want:    "epub",
+		},
+		{
+			name:    "epub named zip",
+			file:    "book.zip",
+			entries: []zipEntry{{"META-INF/container.xml", epubContainer}},
+			want:    "epub",
+		},
+		{
+			name:    "zip of images",
+			file:    "comic.zip",
+			entries: []zipEntry{{"001.jpg", "page"}},
+			want:    "cbz",
+		},
+		{
+			name:    "zip of documents",
+			file:    "papers.zip",
+			entries: []zipEntry{{"paper.pdf", "%PDF-1.7"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(writeFixture(t, tt.file, buildZip(t, tt.entries...)))
+			if tt.want == "" {
+				if !errors.Is(err, ErrNotSupportMimeType) {
+					t.Errorf("Parse() error = %v, want %v", err, ErrNotSupportMimeType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got.File.Type != tt.want {
+				t.Errorf("Parse() type = %q, want %q", got.File.Type, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package bookparser
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+)
+
+type opfPackage struct {
+	Metadata struct {
+		Title       []string        `xml:"title"`
+		Creator     []opfCreator    `xml:"creator"`
+		Identifier  []opfIdentifier `xml:"identifier"`
+		Publisher   []string        `xml:"publisher"`
+		Language    []string        `xml:"language"`
+		Description []string        `xml:"description"`
+		Date        []opfDate       `xml:"date"`
+		Subject     []string        `xml:"subject"`
+		Meta        []opfMeta       `xml:"meta"`
+	} `xml:"metadata"`
+}
+
+type opfCreator struct {
+	Name string `xml:",chardata"`
+	Role string `xml:"role,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+type opfIdentifier struct {
+	Value  string `xml:",chardata"`
+	Scheme string `xml:"scheme,attr"`
+}
+
+type opfDate struct {
+	Value string `xml:",chardata"`
+	Event string `xml:"event,attr"`
+}
+
+type opfMeta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	Refines  string `xml:"refines,attr"`
+	ID       string `xml:"id,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// ParseOPF parses a standalone OPF metadata file, such as the metadata.opf
+// sidecar written by calibre next to book files.
+func ParseOPF(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+
+	var opf opfPackage
+	decoder := xml.NewDecoder(f)
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&opf); err != nil {
+		return Metadata{}, err
+	}
+	m := opf.Metadata
+
+	// EPUB 3 refines creators and collections with meta elements
+	refines := make(map[string]map[string]string)
+	for _, meta := range m.Meta {
+		if meta.Refines == "" {
+			continue
+		}
+		id := strings.TrimPrefix(meta.Refines, "#")
+		if refines[id] == nil {
+			refines[id] = make(map[string]string)
+		}
+		refines[id][meta.Property] = strings.TrimSpace(meta.Value)
+	}
+
+	metadata := Metadata{
+		Publisher:   first(m.Publisher),
+		Language:    first(m.Language),
+		Description: first(m.Description),
+		Title:       first(m.Title),
+	}
+
+	for _, creator := range m.Creator {
+		role := creator.Role
+		if role == "" {
+			role = refines[creator.ID]["role"]
+		}
+		name := strings.TrimSpace(creator.Name)
+		if name != "" && (role == "" || role == "aut") {
+			metadata.Authors = append(metadata.Authors, name)
+		}
+	}
+
+	for _, id := range m.Identifier {
+		value := strings.TrimSpace(id.Value)
+		lower := strings.ToLower(value)
+		switch {
+		case strings.EqualFold(id.Scheme, "isbn"):
+		case strings.HasPrefix(lower, "urn:isbn:"):
+			value = value[len("urn:isbn:"):]
+		case strings.HasPrefix(lower, "isbn:"):
+			value = value[len("isbn:"):]
+		default:
+			continue
+		}
+		metadata.ISBN = value
+		break
+	}
+
+	for _, date := range m.Date {
+		// Prefer the publication event, fallback to the first date
+		if date.Event == "publication" || metadata.PublishDate == "" {
+			metadata.PublishDate = normalizeDate(date.Value)
+		}
+	}
+
+	for _, tag := range m.Subject {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			metadata.Tags = append(metadata.Tags, tag)
+		}
+	}
+
+	for _, meta := range m.Meta {
+		switch {
+		case meta.Name == "calibre:series":
+			metadata.Series = strings.TrimSpace(meta.Content)
+		case meta.Name == "calibre:series_index":
+			metadata.SeriesIndex = parseSeriesIndex(meta.Content)
+		case meta.Property == "belongs-to-collection" && metadata.Series == "":
+			metadata.Series = strings.TrimSpace(meta.Value)
+			metadata.SeriesIndex = parseSeriesIndex(refines[meta.ID]["group-position"])
+		}
+	}
+
+	return metadata, nil
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -8,11 +8,12 @@ import (
 func readMetadataFromPDF(path string) (Metadata, error) {
 	info, err := pdfinfo.Extract(path)
 	if err != nil {
-		return Metadata{Title: getTitleFromFilePath(path)}, nil
+		return fileNameMetadata(path), nil
 	}
 
 	title := info.Key("Title").Text()
-	if title == "" {
+	fileTitle := title == ""
+	if fileTitle {
 		title = getTitleFromFilePath(path)
 	}
 
@@ -31,10 +32,16 @@ func readMetadataFromPDF(path string) (Metadata, error) {
 	}
 
 	return Metadata{
-		ISBN:      info.Key("ISBN").Text(),
-		Title:     title,
-		Authors:   authors,
-		Publisher: info.Key("Creator").Text(),
-		Tags:      tags,
+		ISBN:        info.Key("ISBN").Text(),
+		Title:       title,
+		Authors:     authors,
+		Publisher:   info.Key("Creator").Text(),
+		Language:    info.Key("Language").Text(),
+		Description: info.Key("Description").Text(),
+		PublishDate: normalizeDate(info.Key("CreationDate").Text()),
+		Series:      info.Key("Series").Text(),
+		SeriesIndex: parseSeriesIndex(info.Key("SeriesIndex").Text()),
+		Tags:        tags,
+		FileTitle:   fileTitle,
 	}, nil
 }
@@ -0,0 +1,285 @@
+package bookparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// rtfInfoSize is how much of an RTF file is searched for the info group,
+// which follows the font, color and style tables.
+const rtfInfoSize = 1 << 20
+
+// isRTF check is mime type rtf.
+func isRTF(f io.ReaderAt, size int64) bool {
+	if size < 5 {
+		return false
+	}
+	buf := make([]byte, 5)
+	f.ReadAt(buf, 0)
+	return bytes.Equal(buf, []byte(`{\rtf`))
+}
+
+// rtfCodePages are the encodings of \ansicpg code pages.
+var rtfCodePages = map[int]encoding.Encoding{
+	437:   charmap.CodePage437,
+	850:   charmap.CodePage850,
+	852:   charmap.CodePage852,
+	855:   charmap.CodePage855,
+	858:   charmap.CodePage858,
+	860:   charmap.CodePage860,
+	862:   charmap.CodePage862,
+	863:   charmap.CodePage863,
+	865:   charmap.CodePage865,
+	866:   charmap.CodePage866,
+	874:   charmap.Windows874,
+	932:   japanese.ShiftJIS,
+	936:   simplifiedchinese.GBK,
+	949:   korean.EUCKR,
+	950:   traditionalchinese.Big5,
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	10000: charmap.Macintosh,
+	10007: charmap.MacintoshCyrillic,
+	20866: charmap.KOI8R,
+	21866: charmap.KOI8U,
+	28591: charmap.ISO8859_1,
+	28592: charmap.ISO8859_2,
+	28595: charmap.ISO8859_5,
+	28597: charmap.ISO8859_7,
+	28605: charmap.ISO8859_15,
+	65001: unicode.UTF8,
+}
+
+// rtfScanner reads the text of RTF groups.
+type rtfScanner struct {
+	data []byte
+	pos  int
+	enc  encoding.Encoding // code page of \'hh characters
+	uc   int               // characters to skip after \u, set by \uc
+}
+
+// newRTFScanner returns a scanner of the groups of data starting at pos,
+// with the code page and \uc of the document header.
+func newRTFScanner(data []byte, pos int) rtfScanner {
+	s := rtfScanner{data: data, pos: pos, enc: charmap.Windows1252, uc: 1}
+	header := data[:pos]
+	if cp, ok := headerParam(header, `\ansicpg`); ok && rtfCodePages[cp] != nil {
+		s.enc = rtfCodePages[cp]
+	}
+	if uc, ok := headerParam(header, `\uc`); ok && uc >= 0 {
+		s.uc = uc
+	}
+	return s
+}
+
+// headerParam returns the numeric parameter of the first control word in
+// header.
+func headerParam(header []byte, word string) (int, bool) {
+	for i := 0; ; {
+		j := bytes.Index(header[i:], []byte(word))
+		if j < 0 {
+			return 0, false
+		}
+		i += j + len(word)
+		end := i
+		for end < len(header) && header[end] >= '0' && header[end] <= '9' {
+			end++
+		}
+		if end > i {
+			param, err := strconv.Atoi(string(header[i:end]))
+			return param, err == nil
+		}
+	}
+}
+
+// controlWord reads a control word after a backslash, with its numeric
+// parameter and the space delimiting it.
+func (s *rtfScanner) controlWord() (word string, param int, hasParam bool) {
+	start := s.pos
+	for s.pos < len(s.data) && (s.data[s.pos] >= 'a' && s.data[s.pos] <= 'z' || s.data[s.pos] >= 'A' && s.data[s.pos] <= 'Z') {
+		s.pos++
+	}
+	word = string(s.data[start:s.pos])
+
+	start = s.pos
+	if s.pos < len(s.data) && s.data[s.pos] == '-' {
+		s.pos++
+	}
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	if s.pos > start {
+		param, _ = strconv.Atoi(string(s.data[start:s.pos]))
+		hasParam = true
+	}
+	if s.pos < len(s.data) && s.data[s.pos] == ' ' {
+		s.pos++
+	}
+	return word, param, hasParam
+}
+
+// group reads the text of the group starting after its opening brace, until
+// the closing brace. Control words are returned by fn, which may be nil.
+func (s *rtfScanner) group(fn func(word string, param int)) string {
+	var sb strings.Builder
+	var pending []byte // \'hh bytes, decoded together as characters may take several
+	flush := func() {
+		if len(pending) > 0 {
+			text, err := s.enc.NewDecoder().Bytes(pending)
+			if err == nil {
+				sb.Write(text)
+			}
+			pending = pending[:0]
+		}
+	}
+	skip := 0         // characters to skip after \u
+	uc := []int{s.uc} // \uc of the nested groups
+	emit := func(r rune) {
+		if skip > 0 {
+			skip--
+			return
+		}
+		flush()
+		sb.WriteRune(r)
+	}
+	emitByte := func(b byte) {
+		if skip > 0 {
+			skip--
+			return
+		}
+		pending = append(pending, b)
+	}
+
+	for depth := 1; s.pos < len(s.data); {
+		c := s.data[s.pos]
+		s.pos++
+		switch c {
+		case '{':
+			depth++
+			uc = append(uc, uc[len(uc)-1])
+		case '}':
+			if depth--; depth == 0 {
+				flush()
+				return strings.TrimSpace(sb.String())
+			}
+			uc = uc[:len(uc)-1]
+		case '\r', '\n':
+		case '\\':
+			if s.pos >= len(s.data) {
+				break
+			}
+			switch e := s.data[s.pos]; e {
+			case '\\', '{', '}':
+				s.pos++
+				emit(rune(e))
+			case '\'':
+				if s.pos+3 <= len(s.data) {
+					if b, err := strconv.ParseUint(string(s.data[s.pos+1:s.pos+3]), 16, 8); err == nil {
+						emitByte(byte(b))
+					}
+				}
+				s.pos += 3
+			case '~':
+				s.pos++
+				emit(' ')
+			default:
+				word, param, hasParam := s.controlWord()
+				switch {
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(rune(param))
+					skip = uc[len(uc)-1]
+				case word == "uc" && hasParam && param >= 0:
+					uc[len(uc)-1] = param
+				case word == "par" || word == "line" || word == "tab":
+					emit(' ')
+				case fn != nil:
+					fn(word, param)
+				}
+			}
+		default:
+			emit(rune(c))
+		}
+	}
+	flush()
+	return strings.TrimSpace(sb.String())
+}
+
+// rtfInfo returns the text of the fields of the info group, such as title
+// and author, and the creation date as YYYY-MM-DD in "creatim".
+func rtfInfo(data []byte) map[string]string {
+	info := make(map[string]string)
+	i := bytes.Index(data, []byte(`{\info`))
+	if i < 0 {
+		return info
+	}
+	s := newRTFScanner(data, i+len(`{\info`))
+
+	for s.pos < len(s.data) {
+		c := s.data[s.pos]
+		s.pos++
+		if c == '}' {
+			break
+		}
+		if c != '{' || s.pos >= len(s.data) || s.data[s.pos] != '\\' {
+			continue
+		}
+		s.pos++
+		field, _, _ := s.controlWord()
+
+		date := map[string]int{}
+		text := s.group(func(word string, param int) {
+			date[word] = param
+		})
+		if field == "creatim" && date["yr"] > 0 {
+			text = fmt.Sprintf("%04d-%02d-%02d", date["yr"], date["mo"], date["dy"])
+		}
+		if _, found := info[field]; !found && field != "" && text != "" {
+			info[field] = text
+		}
+	}
+	return info
+}
+
+// parseMetadataFromRTF reads the info group of an RTF document, fallback to
+// the file name.
+func parseMetadataFromRTF(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, rtfInfoSize))
+	if err != nil {
+		return fileNameMetadata(path), nil
+	}
+	info := rtfInfo(data)
+
+	description := info["doccomm"]
+	if description == "" {
+		description = info["subject"]
+	}
+	return documentMetadata(path, info["title"], info["author"], description, "", info["creatim"], splitKeywords(info["keywords"])), nil
+}
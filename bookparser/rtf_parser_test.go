@@ -0,0 +1,114 @@
+package bookparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRTFInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		rtf  string
+		want map[string]string
+	}{
+		{
+			name: "windows-1252 by default",
+			rtf:  `{\rtf1\ansi{\info{\title Caf\'e9 cr\'e8me}{\author Jos\'e9}}}`,
+			want: map[string]string{"title": "Café crème", "author": "José"},
+		},
+		{
+			name: "ansicpg",
+			rtf:  `{\rtf1\ansi\ansicpg1251\deff0{\fonttbl{\f0 Times;}}{\info{\title \'c2\'ee\'e9\'ed\'e0 \'e8 \'ec\'e8\'f0}}}`,
+			want: map[string]string{"title": "Война и мир"},
+		},
+		{
+			name: "multibyte code page",
+			rtf:  `{\rtf1\ansi\ansicpg932{\info{\title \'93\'fa\'96\'7b}}}`,
+			want: map[string]string{"title": "日本"},
+		},
+		{
+			name: "unknown code page",
+			rtf:  `{\rtf1\ansi\ansicpg99999{\info{\title Caf\'e9}}}`,
+			want: map[string]string{"title": "Café"},
+		},
+		{
+			name: "unicode with one fallback character",
+			rtf:  `{\rtf1\ansi{\info{\title \u1042?\u1086?\u1081?\u1085?\u1072?}}}`,
+			want: map[string]string{"title": "Война"},
+		},
+		{
+			name: "uc skips fallback bytes",
+			rtf:  `{\rtf1\ansi\ansicpg932{\info{\title \uc2\u26085\'93\'fa\u26412\'96\'7b}}}`,
+			want: map[string]string{"title": "日本"},
+		},
+		{
+			name: "uc of the header",
+			rtf:  `{\rtf1\ansi\ansicpg932\uc2{\info{\title \u26085\'93\'fa\u26412\'96\'7b}}}`,
+			want: map[string]string{"title": "日本"},
+		},
+		{
+			name: "uc0 without fallback",
+			rtf:  `{\rtf1\ansi{\info{\title \uc0\u1042 \u1072 x}}}`,
+			want: map[string]string{"title": "Ваx"},
+		},
+		{
+			name: "uc ends with its group",
+			rtf:  `{\rtf1\ansi{\info{\title {\uc2\u26085 ??}\u26412?}}}`,
+			want: map[string]string{"title": "日本"},
+		},
+		{
+			name: "negative unicode",
+			rtf:  `{\rtf1\ansi{\info{\title \u-3?}}}`,
+			want: map[string]string{"title": "�"},
+		},
+		{
+			name: "escapes",
+			rtf:  `{\rtf1\ansi{\info{\title a\{b\}\\c\~d\par e}}}`,
+			want: map[string]string{"title": `a{b}\c d e`},
+		},
+		{
+			name: "creation date",
+			rtf:  `{\rtf1\ansi{\info{\creatim\yr2020\mo5\dy9\hr10\min3}{\revtim\yr2021}}}`,
+			want: map[string]string{"creatim": "2020-05-09"},
+		},
+		{
+			name: "first value wins",
+			rtf:  `{\rtf1\ansi{\info{\title One}{\title Two}{\subject }}}`,
+			want: map[string]string{"title": "One"},
+		},
+		{
+			name: "no info group",
+			rtf:  `{\rtf1\ansi Text}`,
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rtfInfo([]byte(tt.rtf)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rtfInfo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetadataFromRTF(t *testing.T) {
+	path := writeFixture(t, "paper.rtf", []byte(`{\rtf1\ansi\ansicpg1252\uc1
+{\info{\title The Paper}{\author Ada Lovelace}{\subject Engines}{\keywords notes; math, history}{\creatim\yr1843\mo9\dy1}}
+Text}`))
+	want := Metadata{
+		Title:       "The Paper",
+		Authors:     []string{"Ada Lovelace"},
+		Description: "Engines",
+		PublishDate: "1843-09-01",
+		Tags:        []string{"notes", "math", "history"},
+	}
+
+	got, err := parseMetadataFromRTF(path)
+	if err != nil {
+		t.Fatalf("parseMetadataFromRTF() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMetadataFromRTF() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package bookparser
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// isText check is mime type txt, a file named .txt without NUL bytes at its
+// start. Plain text can not be told apart from other text files by content,
+// so the extension is required.
+func isText(f io.ReaderAt, path string) bool {
+	if !strings.EqualFold(filepath.Ext(path), ".txt") {
+		return false
+	}
+	buf := make([]byte, 4096)
+	n, _ := f.ReadAt(buf, 0)
+	return bytes.IndexByte(buf[:n], 0) < 0
+}
+
+// parseMetadataFromText returns the metadata of a plain text file, which is
+// given by the file name.
+func parseMetadataFromText(path string) (Metadata, error) {
+	return Metadata{Title: getTitleFromFilePath(path), Authors: []string{}, Tags: []string{}, FileTitle: true}, nil
+}
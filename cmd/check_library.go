@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"ebmgo/bookmanager"
+	"ebmgo/config"
+	"flag"
+	"fmt"
+)
+
+func CheckLibrary(cfg config.Config, call []string) error {
+	flagSet := flag.NewFlagSet("check", flag.PanicOnError)
+	fixFlag := flagSet.Bool("fix", false, "Repair problems that can be repaired safely")
+	pruneMissingFlag := flagSet.Bool("prune-missing", false, "Remove files missing on disk from the db, their books lose them")
+	skipChecksumFlag := flagSet.Bool("skip-checksums", false, "Do not verify file checksums")
+	libraryNameFlag := libraryFlag(flagSet)
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
+
+	if *helpFlag {
+		println("Usage: check [options]\n")
+		println("Options:")
+		flagSet.PrintDefaults()
+		return nil
+	}
+
+	return checkLibrary(cfg, bookmanager.CheckOptions{Fix: *fixFlag, PruneMissing: *pruneMissingFlag, SkipChecksums: *skipChecksumFlag})
+}
+
+func checkLibrary(cfg config.Config, opts bookmanager.CheckOptions) error {
+	ebm, err := openLibrary(cfg)
+	if err != nil {
+		return err
+	}
+	defer ebm.Close()
+
+	problems, err := ebm.Check(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	unfixed := 0
+	for _, p := range problems {
+		line := p.Kind
+		if p.BookID != 0 {
+			line += fmt.Sprintf(" book %d", p.BookID)
+		}
+		if p.Value != "" && p.Kind != bookmanager.ProblemFtsOutOfSync {
+			line += ": " + p.Value
+		}
+		if p.Fixed {
+			line += " (fixed)"
+		} else {
+			unfixed++
+		}
+		fmt.Println(line)
+	}
+
+	if unfixed > 0 {
+		return fmt.Errorf("%d problem(s) found", unfixed)
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"ebmgo/bookmanager"
+	"ebmgo/config"
+	"ebmgo/editor"
+	"flag"
+	"fmt"
+)
+
+func EditBooks(cfg config.Config, call []string) error {
+	flagSet := flag.NewFlagSet("edit", flag.PanicOnError)
+	idsFlag := flagSet.String("ids", "", "Book ID to edit. Separe by ','")
+	queryFlag := flagSet.String("s", "", "Edit books matching the search query")
+	libraryNameFlag := libraryFlag(flagSet)
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
+
+	if *helpFlag {
+		println("Usage: edit [options]\n")
+		println("Options:")
+		flagSet.PrintDefaults()
+		return nil
+	} else if *idsFlag == "" && *queryFlag == "" {
+		return fmt.Errorf("ids or search query is required")
+	}
+
+	var ids []int
+	if *idsFlag != "" {
+		var err error
+		ids, err = parseIDs(*idsFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return editBooks(cfg, ids, *queryFlag)
+}
+
+func editBooks(cfg config.Config, ids []int, query string) error {
+	ebm, err := openLibrary(cfg)
+	if err != nil {
+		return err
+	}
+	defer ebm.Close()
+
+	var books []bookmanager.Book
+	if len(ids) > 0 {
+		books, err = ebm.GetBooksByIDs(ids)
+	} else {
+		books, err = ebm.GetBooks(query, bookmanager.QueryOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		return fmt.Errorf("no books found")
+	}
+
+	edited, err := editor.EditBooks(cfg.Editor, books)
+	if err != nil {
+		return err
+	}
+
+	updated, err := ebm.UpdateBooks(context.Background(), edited)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d book(s) updated\n", updated)
+	return nil
+}
@@ -6,17 +6,19 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
 )
 
-func Export(call []string) error {
+func Export(cfg config.Config, call []string) error {
 	flagSet := flag.NewFlagSet("import", flag.PanicOnError)
 	idsFlag := flagSet.String("ids", "", "Book ID to remove. Separe by ','")
-
+	sanitizeFlag := flagSet.String("sanitize", "", "Sanitize profile of file names: posix, windows-safe, fat32, ascii-only. Default: the profile of the library")
+	libraryNameFlag := libraryFlag(flagSet)
 	helpFlag := flagSet.Bool("h", false, "Show help")
 
 	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
 
 	if *helpFlag {
 		println("Usage: remove [options]\n")
@@ -27,13 +29,17 @@ func Export(call []string) error {
 		return fmt.Errorf("ids is required")
 	}
 
-	var ids []int
-	for _, sID := range strings.Split(*idsFlag, ",") {
-		id, err := strconv.Atoi(sID)
+	ids, err := parseIDs(*idsFlag)
+	if err != nil {
+		return err
+	}
+
+	var profile bookmanager.SanitizeProfile
+	if *sanitizeFlag != "" {
+		profile, err = bookmanager.ParseSanitizeProfile(*sanitizeFlag)
 		if err != nil {
-			return fmt.Errorf("error parse flag ids: %v", err)
+			return err
 		}
-		ids = append(ids, id)
 	}
 
 	args := flagSet.Args()
@@ -48,17 +54,21 @@ func Export(call []string) error {
 		dstPath = args[0]
 	}
 
-	return exportBooks(ids, dstPath)
+	return exportBooks(cfg, ids, dstPath, profile)
 }
 
-func exportBooks(ids []int, dstPath string) error {
-	ebm, err := bookmanager.NewBookManager(bindPath(config.EBMGoLibraryDir))
+func exportBooks(cfg config.Config, ids []int, dstPath string, profile bookmanager.SanitizeProfile) error {
+	ebm, err := openLibrary(cfg)
 	if err != nil {
 		return err
 	}
 	defer ebm.Close()
 
-	if err := ebm.Export(ids, dstPath); err != nil {
+	progress := newProgressPrinter()
+	ebm.OnProgress(progress.book)
+	defer progress.finish()
+
+	if err := ebm.Export(ids, dstPath, profile); err != nil {
 		return err
 	}
 
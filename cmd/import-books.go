@@ -1,25 +1,45 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"ebmgo/bookfinder"
 	"ebmgo/bookmanager"
+	"ebmgo/bookparser"
 	"ebmgo/config"
 	"ebmgo/editor"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 )
 
-func Import(call []string) error {
+func Import(cfg config.Config, call []string) error {
 	flagSet := flag.NewFlagSet("import", flag.PanicOnError)
 	skipEditFlag := flagSet.Bool("y", false, "Skip editing book metadata before import")
 	recursiveFlag := flagSet.Bool("r", false, "import books recursively")
-	workerFlag := flagSet.Int("w", 1, "set worker to import book. Default 1")
+	workerFlag := flagSet.Int("w", cfg.Worker, "set worker to import book")
+	duplicateFlag := flagSet.String("on-duplicate", string(bookmanager.DuplicateSkip), "What to do with files already in the library: skip, add-format, new-book, ask")
+	modeFlag := flagSet.String("mode", string(bookmanager.TransferCopy), "How files are put into the library: copy, move, hardlink, symlink, reflink")
+	fallbackFlag := flagSet.String("link-fallback", string(bookmanager.TransferCopy), "Mode used when a hardlink or reflink is not possible: copy, symlink")
+	continueFlag := flagSet.Bool("continue-on-error", false, "Import the other books when a file can not be parsed, read or copied")
+	coversFlag := flagSet.Bool("covers", false, "Save the cover of comics and MOBI/AZW3 books as an image next to the book file")
+	reportFlag := flagSet.String("report", "", "Save the outcome of every file as JSON to this file")
+	resumeFlag := flagSet.Bool("resume", false, "Continue the last import of the directory, skip files already imported and retry failed ones")
+	batchFlag := flagSet.Int("batch", bookmanager.DefaultImportBatchSize, "Number of books imported per transaction")
+	dryRunFlag := flagSet.Bool("dry-run", false, "Print what would be imported without changing the library")
+	outputFlag := flagSet.String("o", "table", "The output format of -dry-run: "+strings.Join(planOutputs, ", "))
+	libraryNameFlag := libraryFlag(flagSet)
 	helpFlag := flagSet.Bool("h", false, "Show help")
 
 	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
 
 	if *helpFlag {
 		println("Usage: import [options] [directory]\n")
@@ -28,6 +48,27 @@ func Import(call []string) error {
 		return nil
 	}
 
+	onDuplicate, err := bookmanager.ParseDuplicatePolicy(*duplicateFlag)
+	if err != nil {
+		return err
+	}
+
+	mode, err := bookmanager.ParseTransferMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+	fallback, err := bookmanager.ParseTransferMode(*fallbackFlag)
+	if err != nil {
+		return err
+	}
+
+	if *dryRunFlag && *outputFlag != "table" && *outputFlag != "json" {
+		return fmt.Errorf("unknown output format: %s", *outputFlag)
+	}
+	if *batchFlag < 1 {
+		return fmt.Errorf("batch must be at least 1")
+	}
+
 	args := flagSet.Args()
 	var path string
 	if len(args) == 0 {
@@ -39,12 +80,77 @@ func Import(call []string) error {
 	} else {
 		path = args[0]
 	}
+	// The journal of resumable imports is keyed by absolute paths
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	opts := bookmanager.ImportOptions{
+		Worker:          *workerFlag,
+		OnDuplicate:     onDuplicate,
+		Ask:             askDuplicate,
+		Mode:            mode,
+		Fallback:        fallback,
+		ContinueOnError: *continueFlag,
+		BatchSize:       *batchFlag,
+		SaveCovers:      *coversFlag,
+		ReadCover:       readCover,
+	}
+	if *dryRunFlag {
+		return planImport(cfg, opts, *recursiveFlag, *resumeFlag, *outputFlag, path)
+	}
+	return importBook(cfg, opts, *skipEditFlag, *recursiveFlag, *resumeFlag, path, *reportFlag)
+
+}
+
+// readCover reads the cover image of a book file.
+func readCover(file bookmanager.BookFiles, cover string) ([]byte, error) {
+	p := bookparser.BookParser{
+		File:     bookparser.File{Path: file.FilePath, Type: file.FileType},
+		Metadata: bookparser.Metadata{Cover: cover},
+	}
+	return p.ReadCover()
+}
 
-	return importBook(*workerFlag, *skipEditFlag, *recursiveFlag, path)
+// askDuplicate asks the user what to do with a duplicate file.
+func askDuplicate(dup bookmanager.Duplicate) bookmanager.DuplicatePolicy {
+	existing := dup.ExistingPath
+	if dup.BookID != 0 {
+		existing = fmt.Sprintf("%s (book %d)", dup.ExistingPath, dup.BookID)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("%s has the same content as %s\n", dup.FilePath, existing)
+		fmt.Print("[s]kip, [a]dd other formats to the existing book, import as [n]ew book? ")
+		answer, err := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "s", "skip":
+			return bookmanager.DuplicateSkip
+		case "a", "add-format":
+			return bookmanager.DuplicateAddFormat
+		case "n", "new-book":
+			return bookmanager.DuplicateNewBook
+		}
+		if err != nil {
+			return bookmanager.DuplicateSkip
+		}
+	}
+}
 
+// askAfter returns ask ending the progress output before the question.
+func askAfter(progress *progressPrinter, ask func(bookmanager.Duplicate) bookmanager.DuplicatePolicy) func(bookmanager.Duplicate) bookmanager.DuplicatePolicy {
+	return func(dup bookmanager.Duplicate) bookmanager.DuplicatePolicy {
+		progress.finish()
+		return ask(dup)
+	}
 }
 
-func importBook(worker int, skipEdit bool, recursive bool, path string) error {
+// importBook imports the books found in path and prints a summary. With
+// resume, files imported by the last import of path are skipped. With
+// reportPath, the outcome of every file is saved as JSON.
+func importBook(cfg config.Config, opts bookmanager.ImportOptions, skipEdit bool, recursive bool, resume bool, path string, reportPath string) error {
 	// Create a cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -57,23 +163,130 @@ func importBook(worker int, skipEdit bool, recursive bool, path string) error {
 		cancel()
 	}()
 
-	books, err := bookfinder.GetEbooks(worker, recursive, path)
+	ebm, err := openLibrary(cfg)
+	if err != nil {
+		return err
+	}
+	defer ebm.Close()
+
+	run, err := ebm.StartImport(path, resume)
+	if errors.Is(err, bookmanager.ErrNothingToResume) {
+		fmt.Printf("Import of %s is finished, nothing to resume\n", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if run.Resumed {
+		fmt.Printf("Resuming import of %s from %s, %d file(s) already done\n", path, run.CreateDate.Format("2006-01-02 15:04:05"), run.DoneFiles())
+	}
+	opts.Run = run
+
+	progress := newProgressPrinter()
+	defer progress.finish()
+	ebm.OnProgress(progress.book)
+	opts.Ask = askAfter(progress, opts.Ask)
+
+	found, err := bookfinder.Find(path, bookfinder.FindOptions{
+		Worker:          opts.Worker,
+		Recursive:       recursive,
+		ContinueOnError: opts.ContinueOnError,
+		Skip:            run.Done,
+		Progress:        progress.find,
+	})
+	progress.finish()
 	if err != nil {
 		return err
 	}
+	books := found.Books
 
 	if !skipEdit {
-		if err = editor.PrepareBooksForImport(books); err != nil {
+		if err = editor.PrepareBooksForImport(cfg.Editor, books); err != nil {
 			return err
 		}
 	}
 
-	ebm, err := bookmanager.NewBookManager(bindPath(config.EBMGoLibraryDir))
+	report, importErr := ebm.ImportBooks(ctx, books, opts)
+	progress.finish()
+	report.Files = append(report.Files, finderResults(found)...)
+
+	// On an error the summary and the report hold the files handled before it
+	if err := writeSummary(os.Stdout, report); err != nil {
+		return err
+	}
+	if reportPath != "" {
+		if err := writeReportFile(reportPath, report); err != nil {
+			return fmt.Errorf("failed write report: %v", err)
+		}
+	}
+	if importErr != nil {
+		if done := report.Count(bookmanager.OutcomeImported) + report.Count(bookmanager.OutcomeMerged); done > 0 {
+			fmt.Fprintf(os.Stderr, "%d file(s) imported before the error, continue with: ebm import -resume %s\n", done, path)
+		}
+		return importErr
+	}
+	if n := report.Count(bookmanager.OutcomeFailed); n > 0 {
+		return fmt.Errorf("%d file(s) failed", n)
+	}
+
+	return nil
+}
+
+// planImport prints what importBook would do with the books found in path,
+// with their metadata as parsed. Nothing is written.
+func planImport(cfg config.Config, opts bookmanager.ImportOptions, recursive bool, resume bool, output string, path string) error {
+	libraryOpts, err := libraryOptions(cfg)
+	if err != nil {
+		return err
+	}
+	libraryOpts.ReadOnly = true
+	ebm, err := bookmanager.NewBookManager(cfg.Library, libraryOpts)
 	if err != nil {
 		return err
 	}
 	defer ebm.Close()
 
-	err = ebm.ImportBooks(ctx, worker, books)
-	return err
+	var skip func(path string) bool
+	if resume {
+		run, err := ebm.StartImport(path, true)
+		if errors.Is(err, bookmanager.ErrNothingToResume) {
+			fmt.Printf("Import of %s is finished, nothing to resume\n", path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		skip = run.Done
+	}
+
+	progress := newProgressPrinter()
+	defer progress.finish()
+	ebm.OnProgress(progress.book)
+	opts.Ask = askAfter(progress, opts.Ask)
+
+	found, err := bookfinder.Find(path, bookfinder.FindOptions{
+		Worker:          opts.Worker,
+		Recursive:       recursive,
+		ContinueOnError: opts.ContinueOnError,
+		Skip:            skip,
+		Progress:        progress.find,
+	})
+	progress.finish()
+	if err != nil {
+		return err
+	}
+
+	plan, err := ebm.PlanImport(context.Background(), found.Books, opts)
+	progress.finish()
+	if err != nil {
+		return err
+	}
+
+	for _, result := range finderResults(found) {
+		if result.Outcome == bookmanager.OutcomeFailed {
+			plan.Failed = append(plan.Failed, result)
+		}
+	}
+
+	return writePlan(os.Stdout, output, plan, found.Unsupported)
 }
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"ebmgo/bookmanager"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// planOutputs are the output formats of import -dry-run.
+var planOutputs = []string{"table", "json"}
+
+// planJSON is the JSON output of import -dry-run.
+type planJSON struct {
+	Books       []plannedBookJSON `json:"books"`
+	Duplicates  []duplicateJSON   `json:"duplicates"`
+	Unsupported []string          `json:"unsupported"`
+	Failed      []failedJSON      `json:"failed"`
+	Files       int               `json:"files"`
+	TotalBytes  int64             `json:"total_bytes"`
+}
+
+type plannedBookJSON struct {
+	Title   string            `json:"title"`
+	Authors []string          `json:"authors"`
+	BookID  int               `json:"book_id,omitempty"` // existing book the files are added to
+	Files   []plannedFileJSON `json:"files"`
+}
+
+type plannedFileJSON struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Format      string `json:"format"`
+	Size        int64  `json:"size"`
+}
+
+type failedJSON struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type duplicateJSON struct {
+	Path         string `json:"path"`
+	ExistingPath string `json:"existing_path"`
+	BookID       int    `json:"book_id,omitempty"`
+}
+
+// planFiles returns the number of files to import.
+func planFiles(plan bookmanager.ImportPlan) int {
+	n := 0
+	for _, book := range plan.Books {
+		n += len(book.Files)
+	}
+	return n
+}
+
+// plannedBookName returns the title and authors of a planned book.
+func plannedBookName(book bookmanager.PlannedBook) string {
+	if len(book.Authors) == 0 {
+		return book.Title
+	}
+	return book.Title + " - " + strings.Join(book.Authors, " & ")
+}
+
+// writePlan writes an import plan and the unsupported files to w in the
+// output format.
+func writePlan(w io.Writer, output string, plan bookmanager.ImportPlan, unsupported []string) error {
+	switch output {
+	case "table":
+		return writePlanTable(w, plan, unsupported)
+	case "json":
+		return writePlanJSON(w, plan, unsupported)
+	default:
+		return fmt.Errorf("unknown output format: %s", output)
+	}
+}
+
+func writePlanTable(w io.Writer, plan bookmanager.ImportPlan, unsupported []string) error {
+	newBooks, merged := 0, 0
+	for _, book := range plan.Books {
+		if book.BookID != 0 {
+			merged++
+			fmt.Fprintf(w, "ADD    book %d: %s\n", book.BookID, plannedBookName(book))
+		} else {
+			newBooks++
+			fmt.Fprintf(w, "NEW    %s\n", plannedBookName(book))
+		}
+		for _, file := range book.Files {
+			size, _ := fileSize(file.Size)
+			fmt.Fprintf(w, "       %-6s %10s  %s -> %s\n", file.FileType, size, file.Source, file.Destination)
+		}
+	}
+	for _, dup := range plan.Duplicates {
+		existing := dup.ExistingPath
+		if dup.BookID != 0 {
+			existing = fmt.Sprintf("%s (book %d)", dup.ExistingPath, dup.BookID)
+		}
+		fmt.Fprintf(w, "SKIP   %s: same content as %s\n", dup.FilePath, existing)
+	}
+	for _, path := range unsupported {
+		fmt.Fprintf(w, "SKIP   %s: unsupported file type\n", path)
+	}
+	for _, file := range plan.Failed {
+		fmt.Fprintf(w, "FAIL   %s: %s\n", file.Path, file.Reason)
+	}
+
+	total, _ := fileSize(plan.TotalBytes)
+	fmt.Fprintf(w, "\n%d new book(s), %d existing book(s) with new formats, %d file(s), %s\n", newBooks, merged, planFiles(plan), total)
+	_, err := fmt.Fprintf(w, "%d duplicate(s) and %d unsupported file(s) skipped, %d file(s) failed\n", len(plan.Duplicates), len(unsupported), len(plan.Failed))
+
+	return err
+}
+
+func writePlanJSON(w io.Writer, plan bookmanager.ImportPlan, unsupported []string) error {
+	out := planJSON{
+		Books:       []plannedBookJSON{},
+		Duplicates:  []duplicateJSON{},
+		Unsupported: nonNil(unsupported),
+		Failed:      []failedJSON{},
+		Files:       planFiles(plan),
+		TotalBytes:  plan.TotalBytes,
+	}
+	for _, book := range plan.Books {
+		b := plannedBookJSON{Title: book.Title, Authors: nonNil(book.Authors), BookID: book.BookID}
+		for _, file := range book.Files {
+			b.Files = append(b.Files, plannedFileJSON{
+				Source:      file.Source,
+				Destination: file.Destination,
+				Format:      file.FileType,
+				Size:        file.Size,
+			})
+		}
+		out.Books = append(out.Books, b)
+	}
+	for _, file := range plan.Failed {
+		out.Failed = append(out.Failed, failedJSON{Path: file.Path, Reason: file.Reason})
+	}
+	for _, dup := range plan.Duplicates {
+		out.Duplicates = append(out.Duplicates, duplicateJSON{Path: dup.FilePath, ExistingPath: dup.ExistingPath, BookID: dup.BookID})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+
+	return err
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"ebmgo/bookfinder"
+	"ebmgo/bookmanager"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// reportJSON is the JSON report of import -report.
+type reportJSON struct {
+	Summary map[bookmanager.Outcome]int `json:"summary"`
+	Files   []fileResultJSON            `json:"files"`
+}
+
+type fileResultJSON struct {
+	Path         string              `json:"path"`
+	Outcome      bookmanager.Outcome `json:"outcome"`
+	BookID       int                 `json:"book_id,omitempty"`
+	Destination  string              `json:"destination,omitempty"`
+	ExistingPath string              `json:"existing_path,omitempty"`
+	Reason       string              `json:"reason,omitempty"`
+}
+
+// finderResults returns the files skipped by the book finder as import results.
+func finderResults(found bookfinder.Result) []bookmanager.FileResult {
+	var results []bookmanager.FileResult
+	for _, path := range found.Unsupported {
+		results = append(results, bookmanager.FileResult{Path: path, Outcome: bookmanager.OutcomeSkippedUnsupported})
+	}
+	for _, f := range found.Failed {
+		results = append(results, bookmanager.FileResult{Path: f.Path, Outcome: bookmanager.OutcomeFailed, Reason: f.Err.Error()})
+	}
+	return results
+}
+
+// writeSummary writes the failed files and the number of files per outcome.
+func writeSummary(w io.Writer, report bookmanager.ImportReport) error {
+	for _, file := range report.Files {
+		if file.Outcome == bookmanager.OutcomeFailed {
+			fmt.Fprintf(w, "failed %s: %s\n", file.Path, file.Reason)
+		}
+	}
+
+	counts := make([]string, 0, len(bookmanager.Outcomes))
+	for _, outcome := range bookmanager.Outcomes {
+		counts = append(counts, fmt.Sprintf("%s: %d", outcome, report.Count(outcome)))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(counts, ", "))
+
+	return err
+}
+
+// writeReportFile saves the outcome of every file of an import as JSON.
+func writeReportFile(path string, report bookmanager.ImportReport) error {
+	out := reportJSON{
+		Summary: make(map[bookmanager.Outcome]int),
+		Files:   []fileResultJSON{},
+	}
+	for _, outcome := range bookmanager.Outcomes {
+		out.Summary[outcome] = report.Count(outcome)
+	}
+	for _, file := range report.Files {
+		out.Files = append(out.Files, fileResultJSON{
+			Path:         file.Path,
+			Outcome:      file.Outcome,
+			BookID:       file.BookID,
+			Destination:  file.Destination,
+			ExistingPath: file.ExistingPath,
+			Reason:       file.Reason,
+		})
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"ebmgo/config"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// librarySubcommands are the subcommands of library.
+var librarySubcommands = []string{"list", "add", "remove", "default"}
+
+func Library(cfg config.Config, call []string) error {
+	if len(call) == 0 || call[0] == "-h" {
+		println("Usage: library list|add|remove|default\n")
+		println("Commands:")
+		println("   list                 list named libraries, * marks the library in use")
+		println("   add name directory   add a named library")
+		println("   remove name          remove a named library, its books are kept")
+		println("   default name         use the named library when none is selected")
+		return nil
+	}
+
+	switch call[0] {
+	case "list":
+		return listLibraries(cfg, call[1:])
+	case "add":
+		return addLibrary(call[1:])
+	case "remove":
+		return removeLibrary(call[1:])
+	case "default":
+		return setDefaultLibrary(call[1:])
+	default:
+		return fmt.Errorf("unknown library command %q, expected one of: %s", call[0], strings.Join(librarySubcommands, ", "))
+	}
+}
+
+func listLibraries(cfg config.Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: library list")
+	}
+
+	if cfg.LibraryName == "" {
+		fmt.Printf("* %-20s %s\n", "(unnamed)", cfg.Library)
+	}
+	for _, name := range cfg.LibraryNames() {
+		mark := " "
+		if name == cfg.LibraryName {
+			mark = "*"
+		}
+		fmt.Printf("%s %-20s %s\n", mark, name, cfg.Libraries[name].Path)
+	}
+
+	return nil
+}
+
+func addLibrary(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: library add name directory")
+	}
+	name, path := args[0], args[1]
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid library name: %q", name)
+	}
+
+	// Keep '~' so the config works for the same user on other machines
+	if !strings.HasPrefix(path, "~") {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+
+	cfg, err := config.ReadFile()
+	if err != nil {
+		return err
+	}
+	if _, found := cfg.Libraries[name]; found {
+		return fmt.Errorf("library %s already exists", name)
+	}
+	if cfg.Libraries == nil {
+		cfg.Libraries = make(map[string]config.Library)
+	}
+	cfg.Libraries[name] = config.Library{Path: path}
+
+	return config.WriteFile(cfg)
+}
+
+func removeLibrary(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: library remove name")
+	}
+	name := args[0]
+
+	cfg, err := config.ReadFile()
+	if err != nil {
+		return err
+	}
+	if _, found := cfg.Libraries[name]; !found {
+		return fmt.Errorf("unknown library: %s", name)
+	}
+	delete(cfg.Libraries, name)
+	if cfg.DefaultLibrary == name {
+		cfg.DefaultLibrary = ""
+	}
+
+	return config.WriteFile(cfg)
+}
+
+func setDefaultLibrary(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: library default name")
+	}
+	name := args[0]
+
+	cfg, err := config.ReadFile()
+	if err != nil {
+		return err
+	}
+	if _, found := cfg.Libraries[name]; !found {
+		return fmt.Errorf("unknown library: %s", name)
+	}
+	cfg.DefaultLibrary = name
+
+	return config.WriteFile(cfg)
+}
@@ -7,15 +7,25 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"text/template"
 )
 
-func ListBooks(call []string) error {
+func ListBooks(cfg config.Config, call []string) error {
 	flagSet := flag.NewFlagSet("list", flag.PanicOnError)
 	queryFlag := flagSet.String("s", "", "Filter the results by the search query")
-	formatFlag := flagSet.String("f", "title,authors", "The fields to display when listing books in the db. Available fields: title, authors, formats. Default: title,authors.")
+	formatFlag := flagSet.String("f", "", "The fields to display when listing books in the db. Available fields: "+strings.Join(listFieldNames(), ", ")+". Default: the list_fields config, or title,authors for table output and all fields otherwise.")
+	outputFlag := flagSet.String("o", "table", "The output format. Available formats: "+strings.Join(outputFormats, ", ")+".")
+	templateFlag := flagSet.String("t", "", "Print each book with a Go text/template, e.g. '{{.ID}}\\t{{.Title}} by {{join .Authors \", \"}}'. Helpers: join, upper, lower, truncate, date, filesize, formats.")
+	sortFlag := flagSet.String("sort", bookmanager.SortByID, "Sort the results by field[:asc|:desc]. Available fields: id, title, author, added, modified, series (by series then series index).")
+	limitFlag := flagSet.Int("limit", 0, "Maximum number of books to list, 0 for no limit")
+	offsetFlag := flagSet.Int("offset", 0, "Number of books to skip")
+	libraryNameFlag := libraryFlag(flagSet)
 	helpFlag := flagSet.Bool("h", false, "Show help")
 
 	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
 
 	if *helpFlag {
 		println("Usage: list [options]\n")
@@ -24,20 +34,22 @@ func ListBooks(call []string) error {
 		return nil
 	}
 
-	return listBooks(*queryFlag, *formatFlag)
+	opts, err := parseSort(*sortFlag)
+	if err != nil {
+		return err
+	}
+	if *limitFlag < 0 || *offsetFlag < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	opts.Limit = *limitFlag
+	opts.Offset = *offsetFlag
+
+	return listBooks(cfg, *queryFlag, *formatFlag, *outputFlag, *templateFlag, opts)
 }
 
 func isValidFormat(value string) bool {
-	switch value {
-	case "title":
-		return true
-	case "authors":
-		return true
-	case "formats":
-		return true
-	default:
-		return false
-	}
+	_, ok := listFields[value]
+	return ok
 }
 
 func parseFormat(formatFlag string) ([]string, error) {
@@ -76,52 +88,72 @@ func parseFormat(formatFlag string) ([]string, error) {
 	return format, nil
 }
 
-func listBooks(query string, formatFlag string) error {
-	ebm, err := bookmanager.NewBookManager(bindPath(config.EBMGoLibraryDir))
+// parseSort parses a sort flag such as "title" or "added:desc".
+func parseSort(sortFlag string) (bookmanager.QueryOptions, error) {
+	field, direction, _ := strings.Cut(sortFlag, ":")
+	opts := bookmanager.QueryOptions{Sort: field}
+	switch direction {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, fmt.Errorf("unknown sort direction: %s", direction)
+	}
+
+	return opts, nil
+}
+
+func listBooks(cfg config.Config, query string, formatFlag string, output string, templateText string, opts bookmanager.QueryOptions) error {
+	if !isValidOutput(output) {
+		return fmt.Errorf("unknown output format: %s", output)
+	}
+
+	var tmpl *template.Template
+	if templateText != "" {
+		var err error
+		tmpl, err = parseTemplate(templateText)
+		if err != nil {
+			return err
+		}
+	}
+
+	if formatFlag == "" {
+		formatFlag = cfg.ListFields
+	}
+	if formatFlag == "" {
+		if output == "table" {
+			formatFlag = "title,authors"
+		} else {
+			formatFlag = strings.Join(listFieldNames(), ",")
+		}
+	}
+	format, err := parseFormat(formatFlag)
 	if err != nil {
 		return err
 	}
-	defer ebm.Close()
 
-	books, err := ebm.GetBooks(query)
+	ebm, err := openLibrary(cfg)
 	if err != nil {
 		return err
 	}
+	defer ebm.Close()
 
-	format, err := parseFormat(formatFlag)
+	books, err := ebm.GetBooks(query, opts)
 	if err != nil {
 		return err
 	}
 
-	// Print title from given format
-	fmt.Fprintf(os.Stdout, "%-5s", "ID")
-	for _, f := range format {
-		switch f {
-		case "title":
-			fmt.Fprintf(os.Stdout, "%-75s", "Title")
-		case "authors":
-			fmt.Fprintf(os.Stdout, "%-60s", "Author(s)")
-		case "formats":
-			fmt.Fprintf(os.Stdout, "%-60s", "Formats")
-		}
-	}
-	fmt.Fprintln(os.Stdout, "")
-
-	// Print data from given format
-	for _, b := range books {
-		fmt.Fprintf(os.Stdout, "%-5d", b.ID)
-		for _, f := range format {
-			switch f {
-			case "title":
-				fmt.Fprintf(os.Stdout, "%-75s", b.Title)
-			case "authors":
-				fmt.Fprintf(os.Stdout, "%-60s", strings.Join(b.Authors, " & "))
-			case "formats":
-				fmt.Fprintf(os.Stdout, "%v-60s", b.BookFiles)
-			}
-		}
-		fmt.Fprintln(os.Stdout, "")
+	if tmpl != nil {
+		return writeTemplate(os.Stdout, tmpl, books)
 	}
 
-	return nil
+	return writeBooks(os.Stdout, output, format, books)
+}
+
+// seriesName returns series with its index, e.g. "The Lord of the Rings #2".
+func seriesName(b bookmanager.Book) string {
+	if b.Series == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s #%g", b.Series, b.SeriesIndex)
 }
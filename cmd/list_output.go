@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"bytes"
+	"ebmgo/bookmanager"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// outputFormats are the output formats supported by list.
+var outputFormats = []string{"table", "json", "jsonl", "csv", "tsv"}
+
+func isValidOutput(output string) bool {
+	for _, o := range outputFormats {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
+
+// listField is a book field that can be displayed by list.
+// Field names are stable and used as JSON keys and CSV headers.
+type listField struct {
+	header string
+	width  int
+	// value returns the field value for JSON output.
+	value func(b bookmanager.Book) interface{}
+	// text returns the field value for table, CSV and TSV output.
+	text func(b bookmanager.Book) string
+}
+
+var listFields = map[string]listField{
+	"id": {
+		header: "ID", width: 7,
+		value: func(b bookmanager.Book) interface{} { return b.ID },
+		text:  func(b bookmanager.Book) string { return strconv.Itoa(b.ID) },
+	},
+	"title": {
+		header: "Title", width: 75,
+		value: func(b bookmanager.Book) interface{} { return b.Title },
+		text:  func(b bookmanager.Book) string { return b.Title },
+	},
+	"authors": {
+		header: "Author(s)", width: 60,
+		value: func(b bookmanager.Book) interface{} { return nonNil(b.Authors) },
+		text:  func(b bookmanager.Book) string { return strings.Join(b.Authors, " & ") },
+	},
+	"isbn": {
+		header: "ISBN", width: 20,
+		value: func(b bookmanager.Book) interface{} { return b.ISBN },
+		text:  func(b bookmanager.Book) string { return b.ISBN },
+	},
+	"asin": {
+		header: "ASIN", width: 12,
+		value: func(b bookmanager.Book) interface{} { return b.ASIN },
+		text:  func(b bookmanager.Book) string { return b.ASIN },
+	},
+	"publisher": {
+		header: "Publisher", width: 30,
+		value: func(b bookmanager.Book) interface{} { return b.Publisher },
+		text:  func(b bookmanager.Book) string { return b.Publisher },
+	},
+	"language": {
+		header: "Language", width: 10,
+		value: func(b bookmanager.Book) interface{} { return b.Language },
+		text:  func(b bookmanager.Book) string { return b.Language },
+	},
+	"description": {
+		header: "Description", width: 60,
+		value: func(b bookmanager.Book) interface{} { return b.Description },
+		text:  func(b bookmanager.Book) string { return b.Description },
+	},
+	"published": {
+		header: "Published", width: 12,
+		value: func(b bookmanager.Book) interface{} { return b.PublishDate },
+		text:  func(b bookmanager.Book) string { return b.PublishDate },
+	},
+	"series": {
+		header: "Series", width: 40,
+		value: func(b bookmanager.Book) interface{} { return b.Series },
+		text:  func(b bookmanager.Book) string { return b.Series },
+	},
+	"series_index": {
+		header: "#", width: 6,
+		value: func(b bookmanager.Book) interface{} { return b.SeriesIndex },
+		text:  func(b bookmanager.Book) string { return strconv.FormatFloat(b.SeriesIndex, 'g', -1, 64) },
+	},
+	"added": {
+		header: "Added", width: 12,
+		value: func(b bookmanager.Book) interface{} { return b.CreateDate },
+		text:  func(b bookmanager.Book) string { return b.CreateDate.Format("2006-01-02") },
+	},
+	"modified": {
+		header: "Modified", width: 12,
+		value: func(b bookmanager.Book) interface{} { return b.ModifiedDate },
+		text:  func(b bookmanager.Book) string { return b.ModifiedDate.Format("2006-01-02") },
+	},
+	"tags": {
+		header: "Tags", width: 40,
+		value: func(b bookmanager.Book) interface{} { return nonNil(b.Tags) },
+		text:  func(b bookmanager.Book) string { return strings.Join(b.Tags, ", ") },
+	},
+	"formats": {
+		header: "Formats", width: 20,
+		value: func(b bookmanager.Book) interface{} { return bookFormats(b) },
+		text:  func(b bookmanager.Book) string { return strings.Join(bookFormats(b), ", ") },
+	},
+	"files": {
+		header: "Files", width: 80,
+		value: func(b bookmanager.Book) interface{} { return bookPaths(b) },
+		text:  func(b bookmanager.Book) string { return strings.Join(bookPaths(b), ", ") },
+	},
+}
+
+// listFieldNames returns names of list fields in output order.
+func listFieldNames() []string {
+	return []string{
+		"id", "title", "authors", "isbn", "asin", "publisher", "language", "description",
+		"published", "series", "series_index", "tags", "formats", "files", "added", "modified",
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func bookFormats(b bookmanager.Book) []string {
+	formats := []string{}
+	for _, f := range b.BookFiles {
+		formats = append(formats, f.FileType)
+	}
+	return formats
+}
+
+func bookPaths(b bookmanager.Book) []string {
+	paths := []string{}
+	for _, f := range b.BookFiles {
+		paths = append(paths, f.FilePath)
+	}
+	return paths
+}
+
+// writeBooks writes the given fields of books to w in the output format.
+func writeBooks(w io.Writer, output string, format []string, books []bookmanager.Book) error {
+	switch output {
+	case "table":
+		return writeTable(w, format, books)
+	case "json":
+		return writeJSON(w, format, books)
+	case "jsonl":
+		return writeJSONLines(w, format, books)
+	case "csv":
+		return writeCSV(w, format, books)
+	case "tsv":
+		return writeTSV(w, format, books)
+	default:
+		return fmt.Errorf("unknown output format: %s", output)
+	}
+}
+
+// withID returns format with the id field first.
+func withID(format []string) []string {
+	fields := []string{"id"}
+	for _, f := range format {
+		if f != "id" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func writeTable(w io.Writer, format []string, books []bookmanager.Book) error {
+	format = withID(format)
+
+	// Print title from given format
+	for _, f := range format {
+		field := listFields[f]
+		fmt.Fprintf(w, "%-*s", field.width, field.header)
+	}
+	fmt.Fprintln(w, "")
+
+	// Print data from given format
+	for _, b := range books {
+		for _, f := range format {
+			value := listFields[f].text(b)
+			if f == "series" {
+				value = seriesName(b)
+			}
+			fmt.Fprintf(w, "%-*s", listFields[f].width, value)
+		}
+		fmt.Fprintln(w, "")
+	}
+
+	return nil
+}
+
+// marshalBook returns a JSON object with the given fields in order.
+func marshalBook(format []string, b bookmanager.Book) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range format {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f)
+		value, err := json.Marshal(listFields[f].value(b))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON(w io.Writer, format []string, books []bookmanager.Book) error {
+	format = withID(format)
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, b := range books {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		data, err := marshalBook(format, b)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte(']')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(w)
+
+	return err
+}
+
+func writeJSONLines(w io.Writer, format []string, books []bookmanager.Book) error {
+	format = withID(format)
+
+	for _, b := range books {
+		data, err := marshalBook(format, b)
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeCSV(w io.Writer, format []string, books []bookmanager.Book) error {
+	format = withID(format)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(format); err != nil {
+		return err
+	}
+	for _, b := range books {
+		record := make([]string, 0, len(format))
+		for _, f := range format {
+			record = append(record, listFields[f].text(b))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// tsvEscaper escapes values so that a TSV record stays on one line.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func writeTSV(w io.Writer, format []string, books []bookmanager.Book) error {
+	format = withID(format)
+
+	if _, err := fmt.Fprintln(w, strings.Join(format, "\t")); err != nil {
+		return err
+	}
+	for _, b := range books {
+		record := make([]string, 0, len(format))
+		for _, f := range format {
+			record = append(record, tsvEscaper.Replace(listFields[f].text(b)))
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(record, "\t")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
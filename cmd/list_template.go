@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"ebmgo/bookmanager"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helper functions available in list templates.
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"truncate": truncate,
+	"date":     formatDate,
+	"filesize": fileSize,
+	"formats":  bookFormats,
+}
+
+// truncate shortens s to at most n characters, ending with "…" when shortened.
+func truncate(n int, s string) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+	if n == 1 {
+		return "…"
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// formatDate formats a time or a YYYY-MM-DD date string with a Go time layout.
+func formatDate(layout string, date interface{}) (string, error) {
+	switch d := date.(type) {
+	case time.Time:
+		if d.IsZero() {
+			return "", nil
+		}
+		return d.Format(layout), nil
+	case string:
+		if d == "" {
+			return "", nil
+		}
+		for _, l := range []string{"2006-01-02", "2006-01", "2006"} {
+			if t, err := time.Parse(l, d); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+		return d, nil
+	default:
+		return "", fmt.Errorf("date: unsupported type %T", date)
+	}
+}
+
+// fileSize returns a human readable size of a number of bytes, a file path or a book file.
+func fileSize(v interface{}) (string, error) {
+	var size int64
+	switch s := v.(type) {
+	case int:
+		size = int64(s)
+	case int64:
+		size = s
+	case string:
+		info, err := os.Stat(s)
+		if err != nil {
+			return "", err
+		}
+		size = info.Size()
+	case bookmanager.BookFiles:
+		info, err := os.Stat(s.FilePath)
+		if err != nil {
+			return "", err
+		}
+		size = info.Size()
+	default:
+		return "", fmt.Errorf("filesize: unsupported type %T", v)
+	}
+
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size), nil
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp]), nil
+}
+
+// templateEscaper interprets escape sequences typed in a shell quoted template.
+var templateEscaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n")
+
+// unescapeTemplate interprets escape sequences in the text of a template,
+// actions are kept as they are so that their string literals are only
+// unquoted by the template parser, e.g. {{join .Authors "\n"}}.
+func unescapeTemplate(text string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			b.WriteString(templateEscaper.Replace(text))
+			return b.String()
+		}
+		end := actionEnd(text, start+2)
+		b.WriteString(templateEscaper.Replace(text[:start]))
+		b.WriteString(text[start:end])
+		text = text[end:]
+	}
+}
+
+// actionEnd returns the offset after the "}}" closing the action starting
+// at i, skipping quoted strings, characters and comments. The end of text if
+// the action is not closed, which is reported by the template parser.
+func actionEnd(text string, i int) int {
+	for i < len(text) {
+		switch {
+		case strings.HasPrefix(text[i:], "}}"):
+			return i + 2
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return len(text)
+			}
+			i += 2 + end + 2
+		case text[i] == '"' || text[i] == '\'' || text[i] == '`':
+			quote := text[i]
+			i++
+			for i < len(text) && text[i] != quote {
+				if text[i] == '\\' && quote != '`' {
+					i++
+				}
+				i++
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return len(text)
+}
+
+// parseTemplate parses a list template executed for each book.
+func parseTemplate(text string) (*template.Template, error) {
+	text = unescapeTemplate(text)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	return template.New("list").Funcs(templateFuncs).Parse(text)
+}
+
+// writeTemplate executes tmpl over each book.
+func writeTemplate(w io.Writer, tmpl *template.Template, books []bookmanager.Book) error {
+	for _, b := range books {
+		if err := tmpl.Execute(w, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"ebmgo/bookmanager"
+	"ebmgo/config"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func MoveBooks(cfg config.Config, call []string) error {
+	flagSet := flag.NewFlagSet("move", flag.PanicOnError)
+	idsFlag := flagSet.String("ids", "", "Book ID to move. Separe by ','")
+	toFlag := flagSet.String("to", "", "Name of the library to move books to")
+	keepFlag := flagSet.Bool("keep", false, "Keep books in the source library, copy instead of move")
+	libraryNameFlag := libraryFlag(flagSet)
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
+
+	if *helpFlag {
+		println("Usage: move [options]\n")
+		println("Options:")
+		flagSet.PrintDefaults()
+		return nil
+	} else if *idsFlag == "" {
+		return fmt.Errorf("ids is required")
+	} else if *toFlag == "" {
+		return fmt.Errorf("to is required")
+	}
+
+	ids, err := parseIDs(*idsFlag)
+	if err != nil {
+		return err
+	}
+
+	dst, err := cfg.UseLibrary(*toFlag)
+	if err != nil {
+		return err
+	}
+	if dst.Library == cfg.Library {
+		return fmt.Errorf("books are already in library %s", *toFlag)
+	}
+
+	return moveBooks(cfg, dst, ids, *keepFlag)
+}
+
+// moveBooks copies files and metadata of books to the dst library and
+// removes them from the src library unless keep is set. Files whose content
+// is already in dst add the other formats to the existing book.
+func moveBooks(src config.Config, dst config.Config, ids []int, keep bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	srcEBM, err := openLibrary(src)
+	if err != nil {
+		return err
+	}
+	defer srcEBM.Close()
+
+	books, err := srcEBM.GetBooksByIDs(ids)
+	if err != nil {
+		return err
+	}
+	if len(books) != len(ids) {
+		return fmt.Errorf("%d of %d book(s) not found", len(ids)-len(books), len(ids))
+	}
+
+	dstEBM, err := openLibrary(dst)
+	if err != nil {
+		return err
+	}
+	defer dstEBM.Close()
+
+	opts := bookmanager.ImportOptions{
+		Worker:      src.Worker,
+		OnDuplicate: bookmanager.DuplicateAddFormat,
+	}
+	if _, err := dstEBM.ImportBooks(ctx, books, opts); err != nil {
+		return err
+	}
+
+	if !keep {
+		if err := srcEBM.RemoveBooks(ids); err != nil {
+			return fmt.Errorf("books copied but not removed from %s: %v", src.Library, err)
+		}
+	}
+
+	verb := "moved"
+	if keep {
+		verb = "copied"
+	}
+	fmt.Printf("%d book(s) %s to %s\n", len(books), verb, dst.Library)
+
+	return nil
+}
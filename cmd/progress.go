@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"ebmgo/bookfinder"
+	"ebmgo/bookmanager"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	barInterval = 100 * time.Millisecond // between redraws of the progress bar
+	logInterval = 5 * time.Second        // between progress log lines
+	barWidth    = 30
+)
+
+// progressPrinter shows progress events as a progress bar when stdout is a
+// terminal, and otherwise as a log line on stderr every few seconds.
+type progressPrinter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	stage   string    // stage of the last line
+	last    time.Time // when the last line was printed
+	line    string    // last line
+	printed bool      // whether line was printed
+	open    bool      // whether the bar is shown without newline
+}
+
+func newProgressPrinter() *progressPrinter {
+	p := &progressPrinter{out: os.Stderr}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		p.out = os.Stdout
+		p.tty = true
+	}
+	return p
+}
+
+// find shows a progress event of the book finder.
+func (p *progressPrinter) find(e bookfinder.Progress) {
+	p.show("scan", fmt.Sprintf("%-9s %d file(s) found, %d parsed", "scan", e.Discovered, e.Parsed), false)
+}
+
+// book shows a progress event of the book manager.
+func (p *progressPrinter) book(e bookmanager.Progress) {
+	done, total := float64(e.Bytes), float64(e.TotalBytes)
+	if e.TotalBytes == 0 {
+		done, total = float64(e.Files), float64(e.TotalFiles)
+	}
+	ratio := 1.0
+	if total > 0 {
+		// Files growing while they are read can pass the total
+		ratio = min(max(done/total, 0), 1)
+	}
+	filled := int(ratio * barWidth)
+	bar := "[" + strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled) + "]"
+
+	size, _ := fileSize(e.Bytes)
+	totalSize, _ := fileSize(e.TotalBytes)
+	line := fmt.Sprintf("%-9s %s %3.0f%% %d/%d file(s) %s/%s", e.Stage, bar, ratio*100, e.Files, e.TotalFiles, size, totalSize)
+	if eta := e.ETA().Round(time.Second); eta > 0 {
+		line += " ETA " + eta.String()
+	}
+
+	p.show(string(e.Stage), line, e.Files == e.TotalFiles)
+}
+
+// show prints the line of a stage if enough time passed since the last
+// line, or if the stage is done.
+func (p *progressPrinter) show(stage string, line string, done bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if stage != p.stage {
+		p.end()
+		p.stage = stage
+		p.last = time.Time{}
+	}
+	p.line = line
+	p.printed = false
+
+	interval := logInterval
+	if p.tty {
+		interval = barInterval
+	}
+	if !done && time.Since(p.last) < interval {
+		return
+	}
+	p.print()
+}
+
+func (p *progressPrinter) print() {
+	if p.tty {
+		fmt.Fprintf(p.out, "\r\033[K%s", p.line)
+		p.open = true
+	} else {
+		fmt.Fprintln(p.out, p.line)
+	}
+	p.last = time.Now()
+	p.printed = true
+}
+
+// end prints the last line of the stage if it was skipped, and ends the bar.
+func (p *progressPrinter) end() {
+	if p.line != "" && !p.printed {
+		p.print()
+	}
+	if p.open {
+		fmt.Fprintln(p.out)
+		p.open = false
+	}
+	p.line = ""
+}
+
+// finish ends the current stage, before other output is printed.
+func (p *progressPrinter) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.end()
+	p.stage = ""
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"ebmgo/bookfinder"
+	"ebmgo/bookmanager"
+	"ebmgo/config"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+func RebuildDB(cfg config.Config, call []string) error {
+	flagSet := flag.NewFlagSet("rebuild-db", flag.PanicOnError)
+	workerFlag := flagSet.Int("w", cfg.Worker, "set worker to read books")
+	libraryNameFlag := libraryFlag(flagSet)
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
+
+	if *helpFlag {
+		println("Usage: rebuild-db [options]\n")
+		println("Options:")
+		flagSet.PrintDefaults()
+		return nil
+	}
+
+	return rebuildDB(cfg, *workerFlag)
+}
+
+func rebuildDB(cfg config.Config, worker int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	dir := cfg.Library
+	books, err := bookfinder.GetLibraryBooks(worker, dir)
+	if err != nil {
+		return err
+	}
+
+	dbPath, err := bookmanager.RebuildDB(ctx, dir, books, worker)
+	if err != nil {
+		return err
+	}
+
+	files := 0
+	for _, book := range books {
+		files += len(book.BookFiles)
+	}
+	fmt.Printf("%d book(s) with %d file(s) written to %s\n", len(books), files, dbPath)
+	fmt.Printf("Compare it with %s, then rename it to ebm.db to use it\n", filepath.Join(dir, "ebm.db"))
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"ebmgo/config"
+	"flag"
+	"fmt"
+)
+
+func Relayout(cfg config.Config, call []string) error {
+	flagSet := flag.NewFlagSet("relayout", flag.PanicOnError)
+	libraryNameFlag := libraryFlag(flagSet)
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
+
+	if *helpFlag {
+		println("Usage: relayout [options]\n")
+		println("Moves book files to the path given by the path template of the library.\n")
+		println("Options:")
+		flagSet.PrintDefaults()
+		return nil
+	}
+
+	return relayout(cfg)
+}
+
+func relayout(cfg config.Config) error {
+	ebm, err := openLibrary(cfg)
+	if err != nil {
+		return err
+	}
+	defer ebm.Close()
+
+	moved, err := ebm.Relayout(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d file(s) moved\n", moved)
+	return nil
+}
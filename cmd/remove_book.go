@@ -1,20 +1,21 @@
 package cmd
 
 import (
-	"ebmgo/bookmanager"
 	"ebmgo/config"
 	"flag"
 	"fmt"
-	"strconv"
-	"strings"
 )
 
-func RemoveBooks(call []string) error {
+func RemoveBooks(cfg config.Config, call []string) error {
 	flagSet := flag.NewFlagSet("remove", flag.PanicOnError)
 	idsFlag := flagSet.String("ids", "", "Book ID to remove. Separe by ','")
+	libraryNameFlag := libraryFlag(flagSet)
 	helpFlag := flagSet.Bool("h", false, "Show help")
 
 	flagSet.Parse(call)
+	if err := useLibrary(&cfg, *libraryNameFlag); err != nil {
+		return err
+	}
 
 	if *helpFlag {
 		println("Usage: remove [options]\n")
@@ -25,20 +26,16 @@ func RemoveBooks(call []string) error {
 		return fmt.Errorf("ids is required")
 	}
 
-	var ids []int
-	for _, sID := range strings.Split(*idsFlag, ",") {
-		id, err := strconv.Atoi(sID)
-		if err != nil {
-			return fmt.Errorf("error parse flag ids: %v", err)
-		}
-		ids = append(ids, id)
+	ids, err := parseIDs(*idsFlag)
+	if err != nil {
+		return err
 	}
 
-	return removeBooks(ids)
+	return removeBooks(cfg, ids)
 }
 
-func removeBooks(ids []int) error {
-	ebm, err := bookmanager.NewBookManager(bindPath(config.EBMGoLibraryDir))
+func removeBooks(cfg config.Config, ids []int) error {
+	ebm, err := openLibrary(cfg)
 	if err != nil {
 		return err
 	}
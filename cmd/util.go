@@ -1,21 +1,67 @@
 package cmd
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"ebmgo/bookmanager"
+	"ebmgo/config"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
-// bindPath bind '~' to home directory.
-func bindPath(path string) string {
-	if path[0] == '~' {
-		homeDir, err := os.UserHomeDir()
+// parseIDs parses comma separated book ids.
+func parseIDs(value string) ([]int, error) {
+	var ids []int
+	for _, sID := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(sID))
 		if err != nil {
-			log.Fatal("error bind path:", err)
+			return nil, fmt.Errorf("error parse flag ids: %v", err)
 		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// libraryFlag defines the -L flag selecting a named library.
+func libraryFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("L", "", "Name of the library to use, see library list")
+}
+
+// useLibrary selects the named library in cfg, an empty name keeps the current one.
+func useLibrary(cfg *config.Config, name string) error {
+	c, err := cfg.UseLibrary(name)
+	if err != nil {
+		return err
+	}
+	*cfg = c
 
-		path = filepath.Join(homeDir, path[1:])
+	return nil
+}
+
+// openLibrary returns the book manager of the library selected in cfg.
+func openLibrary(cfg config.Config) (*bookmanager.BookManager, error) {
+	opts, err := libraryOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookmanager.NewBookManager(cfg.Library, opts)
+}
+
+// libraryOptions returns the book manager options of the library selected in cfg.
+func libraryOptions(cfg config.Config) (bookmanager.Options, error) {
+	var profile bookmanager.SanitizeProfile
+	if name := cfg.LibrarySanitize(); name != "" {
+		var err error
+		profile, err = bookmanager.ParseSanitizeProfile(name)
+		if err != nil {
+			return bookmanager.Options{}, err
+		}
 	}
 
-	return path
+	return bookmanager.Options{
+		PathTemplate: cfg.LibraryPathTemplate(),
+		Sanitize:     profile,
+	}, nil
 }
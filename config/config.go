@@ -1,4 +1,250 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
 // Directory to save ebook and db
 var EBMGoLibraryDir = "~/EBMGo Library"
+
+// LibraryEnv is the environment variable overriding the library directory.
+const LibraryEnv = "EBMGO_LIBRARY"
+
+// Config is the ebm configuration. Values are resolved by Load in this order,
+// later ones win: defaults, config file, environment, command line flags.
+type Config struct {
+	// Library is the directory to save ebook and db.
+	Library string `json:"library,omitempty"`
+	// LibraryName is the name of the selected library, empty if Library is
+	// not a named library.
+	LibraryName string `json:"-"`
+	// Libraries are the named libraries.
+	Libraries map[string]Library `json:"libraries,omitempty"`
+	// DefaultLibrary is the name of the library used when none is selected.
+	// It takes precedence over Library.
+	DefaultLibrary string `json:"default_library,omitempty"`
+	// Worker is the default number of workers of import and rebuild-db.
+	Worker int `json:"worker,omitempty"`
+	// Editor is the command used to edit metadata, $EDITOR or nano by default.
+	Editor string `json:"editor,omitempty"`
+	// ListFields are the default fields of list, e.g. "title,authors,series".
+	ListFields string `json:"list_fields,omitempty"`
+	// PathTemplate is the Go template of book file paths in libraries
+	// without their own template, e.g. "{{.FirstAuthorSort}}/{{.Title}}".
+	PathTemplate string `json:"path_template,omitempty"`
+	// Sanitize is the profile of file names in libraries without their own
+	// profile: posix, windows-safe, fat32 or ascii-only.
+	Sanitize string `json:"sanitize,omitempty"`
+}
+
+// Library is a named library.
+type Library struct {
+	// Path is the directory to save ebook and db.
+	Path string `json:"path"`
+	// PathTemplate overrides Config.PathTemplate for this library.
+	PathTemplate string `json:"path_template,omitempty"`
+	// Sanitize overrides Config.Sanitize for this library.
+	Sanitize string `json:"sanitize,omitempty"`
+}
+
+// Default returns the configuration used when nothing is configured.
+func Default() Config {
+	return Config{
+		Library: EBMGoLibraryDir,
+		Worker:  1,
+	}
+}
+
+// Path returns the path of the config file,
+// $XDG_CONFIG_HOME/ebmgo/config.json on Linux.
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "ebmgo", "config.json"), nil
+}
+
+// Load resolves the configuration from the config file, the environment,
+// the library flag and the name of the selected library. Empty flags are
+// ignored.
+func Load(libraryFlag string, libraryName string) (Config, error) {
+	cfg := Default()
+
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := readFile(path, &cfg); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.DefaultLibrary != "" {
+		library, ok := cfg.Libraries[cfg.DefaultLibrary]
+		if !ok {
+			return Config{}, fmt.Errorf("config %s: default library %q is not defined", path, cfg.DefaultLibrary)
+		}
+		cfg.Library = library.Path
+		cfg.LibraryName = cfg.DefaultLibrary
+	}
+	if library := os.Getenv(LibraryEnv); library != "" {
+		cfg.Library = library
+		cfg.LibraryName = ""
+	}
+	if libraryFlag != "" {
+		cfg.Library = libraryFlag
+		cfg.LibraryName = ""
+	}
+
+	if cfg.Editor == "" {
+		cfg.Editor = os.Getenv("EDITOR")
+	}
+	if cfg.Editor == "" {
+		cfg.Editor = "nano"
+	}
+	if cfg.Worker < 1 {
+		return Config{}, fmt.Errorf("config %s: worker must be at least 1", path)
+	}
+	if cfg.Library == "" {
+		return Config{}, fmt.Errorf("config %s: library must not be empty", path)
+	}
+	cfg.Library, err = ExpandHome(cfg.Library)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg.UseLibrary(libraryName)
+}
+
+// UseLibrary returns the configuration with the named library selected.
+// An empty name keeps the current library.
+func (c Config) UseLibrary(name string) (Config, error) {
+	if name == "" {
+		return c, nil
+	}
+
+	library, ok := c.Libraries[name]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown library: %s", name)
+	}
+	path, err := ExpandHome(library.Path)
+	if err != nil {
+		return Config{}, err
+	}
+	c.Library = path
+	c.LibraryName = name
+
+	return c, nil
+}
+
+// LibraryPathTemplate returns the path template of the selected library.
+func (c Config) LibraryPathTemplate() string {
+	if library, ok := c.Libraries[c.LibraryName]; ok && library.PathTemplate != "" {
+		return library.PathTemplate
+	}
+	return c.PathTemplate
+}
+
+// LibrarySanitize returns the sanitize profile of the selected library.
+func (c Config) LibrarySanitize() string {
+	if library, ok := c.Libraries[c.LibraryName]; ok && library.Sanitize != "" {
+		return library.Sanitize
+	}
+	return c.Sanitize
+}
+
+// LibraryNames returns the names of the named libraries in order.
+func (c Config) LibraryNames() []string {
+	names := make([]string, 0, len(c.Libraries))
+	for name := range c.Libraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ReadFile returns the content of the config file without defaults and
+// overrides, to be modified and saved with WriteFile.
+func ReadFile() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg Config
+	if err := readFile(path, &cfg); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// readFile reads the config file at path into cfg, a missing file is not an error.
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// WriteFile saves cfg to the config file.
+func WriteFile(cfg Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	// Write to a temp file first so a failed write keeps the old config
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// ExpandHome replaces a leading '~' of path with the home directory.
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error bind path: %v", err)
+	}
+
+	return filepath.Join(homeDir, path[1:]), nil
+}
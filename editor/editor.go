@@ -3,15 +3,78 @@ package editor
 import (
 	"ebmgo/bookmanager"
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // PrepareBooksForImport lets users edit book metadata in their preferred editor before importing.
-func PrepareBooksForImport(books []bookmanager.Book) error {
+func PrepareBooksForImport(editor string, books []bookmanager.Book) error {
+	return editBooks(editor, "ebm-import.json", books, &books)
+}
+
+// EditBooks lets users edit metadata of already imported books in their preferred editor.
+// It returns the books as they were saved by the user. An error is returned if
+// books were added or removed or their IDs changed.
+func EditBooks(editor string, books []bookmanager.Book) ([]bookmanager.Book, error) {
+	var edited []bookmanager.Book
+	if err := editBooks(editor, "ebm-edit.json", books, &edited); err != nil {
+		return nil, err
+	}
+	if err := checkIDs(books, edited); err != nil {
+		return nil, err
+	}
+
+	return edited, nil
+}
+
+// checkIDs returns an error if the IDs of edited are not the IDs of books,
+// each once.
+func checkIDs(books []bookmanager.Book, edited []bookmanager.Book) error {
+	missing := make(map[int]bool, len(books))
+	for _, book := range books {
+		missing[book.ID] = true
+	}
+	var unexpected []string
+	for _, book := range edited {
+		if !missing[book.ID] {
+			unexpected = append(unexpected, strconv.Itoa(book.ID))
+			continue
+		}
+		delete(missing, book.ID)
+	}
+	if len(unexpected) == 0 && len(missing) == 0 {
+		return nil
+	}
+
+	var problems []string
+	if len(unexpected) > 0 {
+		problems = append(problems, "unexpected or repeated "+strings.Join(unexpected, ", "))
+	}
+	if len(missing) > 0 {
+		ids := make([]int, 0, len(missing))
+		for id := range missing {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		removed := make([]string, 0, len(ids))
+		for _, id := range ids {
+			removed = append(removed, strconv.Itoa(id))
+		}
+		problems = append(problems, "missing "+strings.Join(removed, ", "))
+	}
+	return fmt.Errorf("edited books must keep their IDs, nothing was updated: %s", strings.Join(problems, "; "))
+}
+
+// editBooks writes books as JSON to a temp file, opens it in editor
+// and reads the modified JSON back to dst.
+func editBooks(editor string, name string, books []bookmanager.Book, dst *[]bookmanager.Book) error {
 	// Create new folder to store a temp file for user to modiefied later
-	filePath := filepath.Join(os.TempDir(), "ebm-import.json")
+	filePath := filepath.Join(os.TempDir(), name)
 
 	// Convert to JSON
 	jsonData, err := json.MarshalIndent(books, "", "  ")
@@ -23,11 +86,6 @@ func PrepareBooksForImport(books []bookmanager.Book) error {
 	}
 
 	// Open the file in an editor
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "nano"
-	}
-
 	cmd := exec.Command(editor, filePath)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -44,7 +102,7 @@ func PrepareBooksForImport(books []bookmanager.Book) error {
 		return err
 	}
 
-	if err := json.Unmarshal(content, &books); err != nil {
+	if err := json.Unmarshal(content, dst); err != nil {
 		return err
 	}
 
@@ -3,13 +3,24 @@ module ebmgo
 go 1.22.5
 
 require (
-	github.com/clee/gobipocket v0.0.0-20220206070708-3dade03ac470
+	github.com/bodgit/sevenzip v1.5.2
 	github.com/mahesarohman98/pdfinfo v0.0.0-20250313021004-b16f60a34a4e
 	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/nwaples/rardecode/v2 v2.4.1
 	github.com/pirmd/epub v0.3.1
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
 )
 
 require (
-	golang.org/x/net v0.33.0 // indirect
-	golang.org/x/text v0.21.0 // indirect
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/bodgit/plumbing v1.3.0 // indirect
+	github.com/bodgit/windows v1.0.1 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/ulikunitz/xz v0.5.12 // indirect
+	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
 )
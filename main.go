@@ -1,39 +1,54 @@
 package main
 
 import (
+	"ebmgo/config"
+	"flag"
 	"fmt"
 	"os"
 )
 
-func cmdList() {
+func cmdList(flagSet *flag.FlagSet) {
 	println("EBM-GO\n")
 	println("Simple Ebook Management Library In Go\n")
-	println("Commands:")
+	println("Usage: ebm [options] command [command options]\n")
+	println("Options:")
+	flagSet.PrintDefaults()
+	println("\nCommands:")
 	for name, command := range Apps {
 		println("  ", name, " ", command.description)
 	}
 }
 
 func main() {
-	if len(os.Args) <= 1 {
-		cmdList()
-		return
-	}
+	flagSet := flag.NewFlagSet("ebm", flag.ExitOnError)
+	libraryFlag := flagSet.String("library", "", "Library directory, overrides $"+config.LibraryEnv+" and the config file")
+	libraryNameFlag := flagSet.String("L", "", "Name of the library to use, see library list")
+	helpFlag := flagSet.Bool("h", false, "Show help")
+
+	flagSet.Parse(os.Args[1:])
 
-	cmdName := os.Args[1]
-	if cmdName == "-h" {
-		cmdList()
+	args := flagSet.Args()
+	if *helpFlag || len(args) == 0 {
+		cmdList(flagSet)
 		return
 	}
 
+	cmdName := args[0]
 	cmd, ok := Apps[cmdName]
 	if !ok {
-		fmt.Print("Could not find apps \"" + cmdName + "\"")
+		fmt.Fprintln(os.Stderr, "Could not find apps \""+cmdName+"\"")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(*libraryFlag, *libraryNameFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
 	}
-	args := os.Args
 
-	e := cmd.run(args[2:])
+	e := cmd.run(cfg, args[1:])
 	if e != nil {
-		panic(e)
+		fmt.Fprintln(os.Stderr, "error:", e)
+		os.Exit(1)
 	}
 }